@@ -3,8 +3,11 @@ module github.com/mylxsw/router
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/buger/jsonparser v1.0.0
+	github.com/fxamacker/cbor/v2 v2.2.0
 	github.com/mylxsw/container v0.0.0-20200525090619-01208c02b074
 	github.com/pkg/errors v0.9.1
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	gopkg.in/yaml.v2 v2.3.0
 )
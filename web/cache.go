@@ -0,0 +1,130 @@
+package web
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a cached response
+type CacheEntry struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+	// CreatedAt is the time the response is generated
+	CreatedAt time.Time
+	// ExpiresAt is the time the response becomes stale
+	ExpiresAt time.Time
+	// StaleUntil is the time until which the stale response can be served while revalidating
+	StaleUntil time.Time
+}
+
+// Fresh return whether the entry is not expired
+func (entry *CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(entry.ExpiresAt)
+}
+
+// Stale return whether the entry is expired but still usable while revalidating
+func (entry *CacheEntry) Stale(now time.Time) bool {
+	return !entry.Fresh(now) && now.Before(entry.StaleUntil)
+}
+
+// CacheStore is a interface for response cache storage
+type CacheStore interface {
+	// Get return the entry for key, expired entries can be returned, the caller checks freshness
+	Get(key string) (*CacheEntry, bool)
+	// Set save the entry for key, the store can remove it after ttl
+	Set(key string, entry *CacheEntry, ttl time.Duration)
+	// Delete remove the entry for key
+	Delete(key string)
+}
+
+// MemoryCacheStore is a in-memory CacheStore which evicts the least recently used entries when it's full
+type MemoryCacheStore struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	lru      *list.List
+}
+
+type memoryCacheItem struct {
+	key      string
+	entry    *CacheEntry
+	expireAt time.Time
+}
+
+// NewMemoryCacheStore create a MemoryCacheStore holds at most capacity entries
+func NewMemoryCacheStore(capacity int) *MemoryCacheStore {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+
+	return &MemoryCacheStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (store *MemoryCacheStore) Get(key string) (*CacheEntry, bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	elem, ok := store.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := elem.Value.(*memoryCacheItem)
+	if !item.expireAt.IsZero() && time.Now().After(item.expireAt) {
+		store.remove(elem)
+		return nil, false
+	}
+
+	store.lru.MoveToFront(elem)
+	return item.entry, true
+}
+
+func (store *MemoryCacheStore) Set(key string, entry *CacheEntry, ttl time.Duration) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+
+	if elem, ok := store.items[key]; ok {
+		elem.Value = &memoryCacheItem{key: key, entry: entry, expireAt: expireAt}
+		store.lru.MoveToFront(elem)
+		return
+	}
+
+	store.items[key] = store.lru.PushFront(&memoryCacheItem{key: key, entry: entry, expireAt: expireAt})
+	for store.lru.Len() > store.capacity {
+		store.remove(store.lru.Back())
+	}
+}
+
+func (store *MemoryCacheStore) Delete(key string) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if elem, ok := store.items[key]; ok {
+		store.remove(elem)
+	}
+}
+
+// Len return the count of entries in store
+func (store *MemoryCacheStore) Len() int {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	return store.lru.Len()
+}
+
+func (store *MemoryCacheStore) remove(elem *list.Element) {
+	store.lru.Remove(elem)
+	delete(store.items, elem.Value.(*memoryCacheItem).key)
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrUnsupportedMediaType is the error when there is no codec for the request Content-Type
+var ErrUnsupportedMediaType = WrapPlainError(errors.New("unsupported media type"), http.StatusUnsupportedMediaType)
+
+// Codec is a interface for encoding and decoding request/response body
+type Codec interface {
+	// ContentType return the Content-Type header value for encoded content, eg. application/json; charset=utf-8
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// CodecRegistry is a registry of body codecs keyed by media type
+type CodecRegistry struct {
+	lock       sync.RWMutex
+	codecs     map[string]Codec
+	mediaTypes []string
+}
+
+// NewCodecRegistry create a empty CodecRegistry
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{
+		codecs:     make(map[string]Codec),
+		mediaTypes: make([]string, 0),
+	}
+}
+
+// DefaultCodecRegistry create a CodecRegistry with built-in JSON, YAML, XML, MessagePack and CBOR codecs
+func DefaultCodecRegistry() *CodecRegistry {
+	registry := NewCodecRegistry()
+	registry.Register(JSONCodec{})
+	registry.Register(YAMLCodec{}, "application/x-yaml", "text/yaml")
+	registry.Register(XMLCodec{}, "text/xml")
+	registry.Register(MsgPackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	registry.Register(CBORCodec{})
+
+	return registry
+}
+
+// Register add a codec to registry, the codec will be keyed by its media type and all aliases
+// codecs registered with existing media type will replace the old ones
+func (registry *CodecRegistry) Register(codec Codec, aliases ...string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	for _, mediaType := range append([]string{parseMediaType(codec.ContentType())}, aliases...) {
+		mediaType = parseMediaType(mediaType)
+		if _, ok := registry.codecs[mediaType]; !ok {
+			registry.mediaTypes = append(registry.mediaTypes, mediaType)
+		}
+
+		registry.codecs[mediaType] = codec
+	}
+}
+
+// Get return a codec for media type, parameters in media type are ignored
+// structured syntax suffix like application/problem+json falls back to application/json
+func (registry *CodecRegistry) Get(mediaType string) (Codec, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	mediaType = parseMediaType(mediaType)
+	if codec, ok := registry.codecs[mediaType]; ok {
+		return codec, true
+	}
+
+	if pos := strings.LastIndex(mediaType, "+"); pos >= 0 {
+		codec, ok := registry.codecs["application/"+mediaType[pos+1:]]
+		return codec, ok
+	}
+
+	return nil, false
+}
+
+// MediaTypes return all registered media types in order of registration
+func (registry *CodecRegistry) MediaTypes() []string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	return append([]string{}, registry.mediaTypes...)
+}
+
+// parseMediaType return the lower-case media type without parameters
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	return mediaType
+}
+
+// JSONCodec is a codec for application/json
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// YAMLCodec is a codec for application/yaml
+type YAMLCodec struct{}
+
+func (YAMLCodec) ContentType() string {
+	return "application/yaml; charset=utf-8"
+}
+
+func (YAMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (YAMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// XMLCodec is a codec for application/xml
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// MsgPackCodec is a codec for application/msgpack
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// CBORCodec is a codec for application/cbor
+type CBORCodec struct{}
+
+func (CBORCodec) ContentType() string {
+	return "application/cbor"
+}
+
+func (CBORCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
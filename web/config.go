@@ -5,13 +5,35 @@ type Config struct {
 	MultipartFormMaxMemory int64  // Multipart-form 解析占用最大内存
 	TempDir                string // 临时目录，用于上传文件等
 	TempFilePattern        string // 临时文件规则
+	MaxBodySize            int64  // 请求体最大字节数，0 表示不限制，可以被路由的 MaxBodySize 覆盖（路由设置为 UnlimitedBodySize 表示不限制）
+	MaxUploadFileSize      int64  // 单个上传文件最大字节数，0 表示不限制
+	// AllowedUploadMimeTypes 允许上传的文件类型（根据文件内容检测），支持 image/* 形式的通配符，为空表示不限制
+	AllowedUploadMimeTypes []string
+	// InputPrecedence 输入参数来源优先级，Input 等方法按照该顺序查找参数，未包含的来源将被忽略
+	InputPrecedence []InputSource
 }
 
+// InputSource is the source of a request input
+type InputSource string
+
+const (
+	// InputSourceJSON identify the input comes from json request body
+	InputSourceJSON InputSource = "json"
+	// InputSourceForm identify the input comes from urlencoded or multipart form body
+	InputSourceForm InputSource = "form"
+	// InputSourceQuery identify the input comes from url query string
+	InputSourceQuery InputSource = "query"
+)
+
+// defaultInputPrecedence is the input precedence used when Config.InputPrecedence is empty
+var defaultInputPrecedence = []InputSource{InputSourceJSON, InputSourceForm, InputSourceQuery}
+
 // DefaultConfig create a default config
 func DefaultConfig() *Config {
 	return &Config{
 		MultipartFormMaxMemory: int64(10 << 20), // 10M
 		TempDir:                "/tmp",
 		TempFilePattern:        "glacier-files-",
+		InputPrecedence:        defaultInputPrecedence,
 	}
 }
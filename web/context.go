@@ -2,7 +2,10 @@ package web
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"path/filepath"
+	"time"
 
 	"github.com/mylxsw/container"
 )
@@ -12,6 +15,8 @@ type webContext struct {
 	request   Request
 	cc        container.Container
 	conf      *Config
+	route     Route
+	router    *Router
 }
 
 // NewWebContext create new WebContext
@@ -20,6 +25,7 @@ func NewWebContext(router *Router, pathVars map[string]string, writer http.Respo
 		cc:        router.cc,
 		responsor: NewResponseCreator(writer),
 		request:   NewRequest(router.cc, router.conf, request, pathVars),
+		router:    router,
 	}
 }
 
@@ -31,6 +37,19 @@ func (w *webContext) YAML(res interface{}) *YAMLResponse {
 	return NewYAMLResponse(w.responsor, http.StatusOK, res)
 }
 
+func (w *webContext) Encode(mediaType string, res interface{}) *CodecResponse {
+	return NewCodecResponse(w.responsor, w.codecs(), mediaType, http.StatusOK, res)
+}
+
+func (w *webContext) Negotiate(res interface{}) *NegotiateResponse {
+	return NewNegotiateResponse(w.responsor, w.codecs(), w.request.Header("Accept"), http.StatusOK, res)
+}
+
+// codecs return the codec registry from container
+func (w *webContext) codecs() *CodecRegistry {
+	return w.cc.MustGet((*CodecRegistry)(nil)).(*CodecRegistry)
+}
+
 func (w *webContext) JSONWithCode(res interface{}, code int) *JSONResponse {
 	return NewJSONResponse(w.responsor, code, res)
 }
@@ -63,10 +82,18 @@ func (w *webContext) HTMLWithCode(res string, code int) *HTMLResponse {
 	return NewHTMLResponse(w.responsor, code, res)
 }
 
+func (w *webContext) View(name string, data interface{}) *ViewResponse {
+	return NewViewResponse(w, http.StatusOK, name, data)
+}
+
 func (w *webContext) Error(res string, code int) *ErrorResponse {
 	return NewErrorResponse(w.responsor, res, code)
 }
 
+func (w *webContext) Problem(problem *Problem) *ProblemResponse {
+	return NewProblemResponse(w.responsor, problem)
+}
+
 func (w *webContext) JSONError(res string, code int) *JSONResponse {
 	return w.JSONWithCode(M{"error": res}, code)
 }
@@ -75,6 +102,59 @@ func (w *webContext) Redirect(location string, code int) *RedirectResponse {
 	return NewRedirectResponse(w.responsor, w.request, location, code)
 }
 
+func (w *webContext) Stream(contentType string, fn func(w io.Writer) error) *StreamResponse {
+	return w.withStreamExceptionHandler(NewStreamResponse(w.responsor, w.request, http.StatusOK, fn).WithContentType(contentType))
+}
+
+func (w *webContext) StreamReader(contentType string, reader io.Reader) *StreamResponse {
+	return w.withStreamExceptionHandler(NewStreamReaderResponse(w.responsor, w.request, http.StatusOK, reader).WithContentType(contentType))
+}
+
+// withStreamExceptionHandler let errors returned before the stream writes anything be handled by router's exception pipeline
+func (w *webContext) withStreamExceptionHandler(resp *StreamResponse) *StreamResponse {
+	if w.router != nil {
+		resp.exceptionHandler = func(err error) Response {
+			return w.router.handleException(w, err)
+		}
+	}
+
+	return resp
+}
+
+// exceptionResponse create the response for err by router's exception pipeline, so that decorators which
+// reject requests return the error response to outer decorators(eg. AccessLog) instead of panicking through them
+func exceptionResponse(ctx Context, err error) Response {
+	if wtx, ok := ctx.(*webContext); ok && wtx.router != nil {
+		return wtx.router.handleException(wtx, err)
+	}
+
+	panic(err)
+}
+
+func (w *webContext) SSE(fn func(sse *SSEWriter) error) *SSEResponse {
+	return NewSSEResponse(w.responsor, w.request, fn)
+}
+
+func (w *webContext) ServeFile(path string) *FileResponse {
+	return NewFileResponse(w.responsor, w.request, path)
+}
+
+func (w *webContext) ServeFileSystem(fs http.FileSystem, name string) *FileResponse {
+	return NewFileSystemResponse(w.responsor, w.request, fs, name)
+}
+
+func (w *webContext) Download(path string, filename string) *FileResponse {
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+
+	return NewFileResponse(w.responsor, w.request, path).Attachment(filename)
+}
+
+func (w *webContext) Attachment(content io.ReadSeeker, filename string, modTime time.Time) *FileResponse {
+	return NewReadSeekerResponse(w.responsor, w.request, content, filename, modTime).Attachment(filename)
+}
+
 func (w *webContext) Decode(v interface{}) error {
 	return w.request.Decode(v)
 }
@@ -87,6 +167,10 @@ func (w *webContext) UnmarshalYAML(v interface{}) error {
 	return w.request.UnmarshalYAML(v)
 }
 
+func (w *webContext) UnmarshalAuto(v interface{}) error {
+	return w.request.UnmarshalAuto(v)
+}
+
 func (w *webContext) PathVar(key string) string {
 	return w.request.PathVar(key)
 }
@@ -139,6 +223,86 @@ func (w *webContext) Float64Input(key string, defaultVal float64) float64 {
 	return w.request.Float64Input(key, defaultVal)
 }
 
+func (w *webContext) InputArray(key string) []string {
+	return w.request.InputArray(key)
+}
+
+func (w *webContext) InputMap(key string) map[string]string {
+	return w.request.InputMap(key)
+}
+
+func (w *webContext) IntArray(key string) []int {
+	return w.request.IntArray(key)
+}
+
+func (w *webContext) Int64Array(key string) []int64 {
+	return w.request.Int64Array(key)
+}
+
+func (w *webContext) Float32Array(key string) []float32 {
+	return w.request.Float32Array(key)
+}
+
+func (w *webContext) Float64Array(key string) []float64 {
+	return w.request.Float64Array(key)
+}
+
+func (w *webContext) Query(key string) string {
+	return w.request.Query(key)
+}
+
+func (w *webContext) QueryWithDefault(key string, defaultVal string) string {
+	return w.request.QueryWithDefault(key, defaultVal)
+}
+
+func (w *webContext) QueryArray(key string) []string {
+	return w.request.QueryArray(key)
+}
+
+func (w *webContext) IntQuery(key string, defaultVal int) int {
+	return w.request.IntQuery(key, defaultVal)
+}
+
+func (w *webContext) Int64Query(key string, defaultVal int64) int64 {
+	return w.request.Int64Query(key, defaultVal)
+}
+
+func (w *webContext) Float32Query(key string, defaultVal float32) float32 {
+	return w.request.Float32Query(key, defaultVal)
+}
+
+func (w *webContext) Float64Query(key string, defaultVal float64) float64 {
+	return w.request.Float64Query(key, defaultVal)
+}
+
+func (w *webContext) PostForm(key string) string {
+	return w.request.PostForm(key)
+}
+
+func (w *webContext) PostFormWithDefault(key string, defaultVal string) string {
+	return w.request.PostFormWithDefault(key, defaultVal)
+}
+
+func (w *webContext) PostFormArray(key string) []string {
+	return w.request.PostFormArray(key)
+}
+
+func (w *webContext) IntPostForm(key string, defaultVal int) int {
+	return w.request.IntPostForm(key, defaultVal)
+}
+
+func (w *webContext) Int64PostForm(key string, defaultVal int64) int64 {
+	return w.request.Int64PostForm(key, defaultVal)
+}
+
+func (w *webContext) Float32PostForm(key string, defaultVal float32) float32 {
+	return w.request.Float32PostForm(key, defaultVal)
+}
+
+func (w *webContext) Float64PostForm(key string, defaultVal float64) float64 {
+	return w.request.Float64PostForm(key, defaultVal)
+}
+
 func (w *webContext) IsXMLHTTPRequest() bool {
 	return w.request.IsXMLHTTPRequest()
 }
@@ -207,10 +371,22 @@ func (w *webContext) Body() []byte {
 	return w.request.Body()
 }
 
+func (w *webContext) BodyReader() io.ReadCloser {
+	return w.request.BodyReader()
+}
+
 func (w *webContext) File(key string) (*UploadedFile, error) {
 	return w.request.File(key)
 }
 
+func (w *webContext) Files(key string) ([]*UploadedFile, error) {
+	return w.request.Files(key)
+}
+
+func (w *webContext) Multipart() (*MultipartIterator, error) {
+	return w.request.Multipart()
+}
+
 func (w *webContext) Set(key string, value interface{}) {
 	w.request.Set(key, value)
 }
@@ -243,6 +419,11 @@ func (w *webContext) Container() container.Container {
 	return w.cc
 }
 
+// Route return the matched route, it's nil when no route matches
+func (w *webContext) Route() Route {
+	return w.route
+}
+
 func (w *webContext) Validate(validator Validator, jsonResponse bool) {
 	w.request.Validate(validator, jsonResponse)
 }
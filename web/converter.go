@@ -0,0 +1,210 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// ResultConverter convert a handler return value to Response
+type ResultConverter func(ctx Context, val interface{}) Response
+
+// ResultConverters is a registry of converters which turn handler return values into responses
+// converters are matched by exact type first, then by interface(the latest registered first), then by kind
+// values not matched by any converter are rendered as json(or negotiated when content negotiation is enabled),
+// handler results which are already Response are sent directly and never passed to converters
+type ResultConverters struct {
+	lock       sync.RWMutex
+	exact      map[reflect.Type]ResultConverter
+	interfaces []interfaceConverter
+	kinds      map[reflect.Kind]ResultConverter
+}
+
+type interfaceConverter struct {
+	typ       reflect.Type
+	converter ResultConverter
+}
+
+// NewResultConverters create a empty ResultConverters
+func NewResultConverters() *ResultConverters {
+	return &ResultConverters{
+		exact:      make(map[reflect.Type]ResultConverter),
+		interfaces: make([]interfaceConverter, 0),
+		kinds:      make(map[reflect.Kind]ResultConverter),
+	}
+}
+
+// DefaultResultConverters create a ResultConverters with built-in converters
+// string and numbers are rendered as html, io.Reader and channels are streamed to client
+// []byte is sent as encoded json content like before, register BinaryConverter to send it as binary
+func DefaultResultConverters() *ResultConverters {
+	converters := NewResultConverters()
+
+	converters.Register((*io.Reader)(nil), readerConverter)
+	converters.Register((*string)(nil), func(ctx Context, val interface{}) Response {
+		return ctx.HTML(val.(string))
+	})
+
+	integer := func(ctx Context, val interface{}) Response {
+		return ctx.HTML(fmt.Sprintf("%d", val))
+	}
+	for _, typ := range []interface{}{(*int)(nil), (*int8)(nil), (*int16)(nil), (*int32)(nil), (*int64)(nil), (*uint)(nil), (*uint8)(nil), (*uint16)(nil), (*uint32)(nil), (*uint64)(nil)} {
+		converters.Register(typ, integer)
+	}
+
+	float := func(ctx Context, val interface{}) Response {
+		return ctx.HTML(fmt.Sprintf("%f", val))
+	}
+	converters.Register((*float32)(nil), float)
+	converters.Register((*float64)(nil), float)
+
+	converters.RegisterKind(reflect.Chan, channelConverter)
+
+	return converters
+}
+
+// Register register a converter for a type, the type is specified by a nil pointer to it
+// for example (*Paginated)(nil) for type Paginated, (**Paginated)(nil) for type *Paginated,
+// or (*fmt.Stringer)(nil) for all types implement fmt.Stringer
+func (c *ResultConverters) Register(typ interface{}, converter ResultConverter) *ResultConverters {
+	t := reflect.TypeOf(typ)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("result type must be specified by a nil pointer to it, got %v", t))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t = t.Elem()
+	if t.Kind() == reflect.Interface {
+		c.interfaces = append(c.interfaces, interfaceConverter{typ: t, converter: converter})
+	} else {
+		c.exact[t] = converter
+	}
+
+	return c
+}
+
+// RegisterKind register a converter for all types of a kind, eg. reflect.Chan
+func (c *ResultConverters) RegisterKind(kind reflect.Kind, converter ResultConverter) *ResultConverters {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.kinds[kind] = converter
+	return c
+}
+
+// Convert convert val to Response, returns false if there is no converter for val
+func (c *ResultConverters) Convert(ctx Context, val interface{}) (Response, bool) {
+	converter := c.lookup(reflect.TypeOf(val))
+	if converter == nil {
+		return nil, false
+	}
+
+	return converter(ctx, val), true
+}
+
+func (c *ResultConverters) lookup(t reflect.Type) ResultConverter {
+	if t == nil {
+		return nil
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if converter, ok := c.exact[t]; ok {
+		return converter
+	}
+
+	for i := len(c.interfaces) - 1; i >= 0; i-- {
+		if t.Implements(c.interfaces[i].typ) {
+			return c.interfaces[i].converter
+		}
+	}
+
+	return c.kinds[t.Kind()]
+}
+
+// BinaryConverter render a []byte as application/octet-stream content, it's not registered by default
+//
+//	converters.Register((*[]byte)(nil), web.BinaryConverter)
+func BinaryConverter(ctx Context, val interface{}) Response {
+	ctx.Response().SetCode(http.StatusOK)
+	ctx.Response().Header("Content-Type", "application/octet-stream")
+	ctx.Response().SetContent(val.([]byte))
+	return ctx.Plain()
+}
+
+// readerConverter stream the content of a io.Reader
+// if the reader is a io.Closer, it's closed when the request finished, no matter whether the response is sent
+func readerConverter(ctx Context, val interface{}) Response {
+	reader := val.(io.Reader)
+	resp := ctx.StreamReader("application/octet-stream", reader)
+
+	if closer, ok := reader.(io.Closer); ok {
+		if req, ok := ctx.Request().(*httpRequest); ok {
+			req.closeOnCleanup(closer)
+		} else {
+			resp.OnSent(func(Response, error) { _ = closer.Close() })
+		}
+	}
+
+	return resp
+}
+
+// channelConverter stream values received from a channel until it's closed or client disconnected
+// string and []byte values are written as it is, others are written as json lines
+func channelConverter(ctx Context, val interface{}) Response {
+	ch := reflect.ValueOf(val)
+	if ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return ctx.JSONError(fmt.Sprintf("can not receive from %v", ch.Type()), http.StatusInternalServerError)
+	}
+
+	contentType := "application/x-ndjson"
+	switch ch.Type().Elem().Kind() {
+	case reflect.String:
+		contentType = "text/plain; charset=utf-8"
+	case reflect.Slice:
+		if ch.Type().Elem().Elem().Kind() == reflect.Uint8 {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	return ctx.Stream(contentType, func(w io.Writer) error {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: ch},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Context().Done())},
+		}
+
+		for {
+			chosen, item, ok := reflect.Select(cases)
+			if chosen == 1 {
+				return ctx.Context().Err()
+			}
+
+			if !ok {
+				return nil
+			}
+
+			if err := writeChannelItem(w, item.Interface()); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func writeChannelItem(w io.Writer, item interface{}) error {
+	switch v := item.(type) {
+	case string:
+		_, err := io.WriteString(w, v)
+		return err
+	case []byte:
+		_, err := w.Write(v)
+		return err
+	default:
+		return json.NewEncoder(w).Encode(item)
+	}
+}
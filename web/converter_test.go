@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+func TestBytesResultConverter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		binary      bool
+		contentType string
+		body        string
+	}{
+		{
+			name:        "encoded json by default",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"router"}`,
+		},
+		{
+			name:        "binary converter",
+			binary:      true,
+			contentType: "application/octet-stream",
+			body:        `{"name":"router"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		router := NewRouter(container.New(), DefaultConfig())
+		if tc.binary {
+			router.Converters().Register((*[]byte)(nil), BinaryConverter)
+		}
+
+		router.Get("/", func() []byte { return []byte(`{"name":"router"}`) })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if contentType := w.Header().Get("Content-Type"); contentType != tc.contentType {
+			t.Errorf("%s: expect content type %q, got %q", tc.name, tc.contentType, contentType)
+		}
+
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+	}
+}
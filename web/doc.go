@@ -1,8 +1,12 @@
 package web
 
 import (
+	"bufio"
 	"context"
+	"io"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/mylxsw/container"
 )
@@ -23,6 +27,10 @@ type Route interface {
 	Path() string
 	ContentTypes() []string
 	Decorators() []HandlerDecorator
+	MaxBodySize() int64
+	Timeout() time.Duration
+	Name() string
+	URL(vars map[string]string) (string, error)
 
 	WithHost(hosts ...string)
 	WithPath(path string)
@@ -30,6 +38,9 @@ type Route interface {
 	WithContentTypes(contentTypes ...string)
 	WithDecorators(decors ...HandlerDecorator)
 	WithHandler(handler interface{})
+	WithMaxBodySize(size int64)
+	WithTimeout(timeout time.Duration)
+	WithName(name string)
 	PrependDecorators(decors ...HandlerDecorator)
 }
 
@@ -40,18 +51,30 @@ type Context interface {
 	JSONError(res string, code int) *JSONResponse
 
 	YAML(res interface{}) *YAMLResponse
+	Encode(mediaType string, res interface{}) *CodecResponse
+	Negotiate(res interface{}) *NegotiateResponse
 	Nil() *NilResponse
 	Plain() *RawResponse
 
 	HTML(res string) *HTMLResponse
 	HTMLWithCode(res string, code int) *HTMLResponse
+	View(name string, data interface{}) *ViewResponse
 
 	Error(res string, code int) *ErrorResponse
+	Problem(problem *Problem) *ProblemResponse
 	Redirect(location string, code int) *RedirectResponse
+	Stream(contentType string, fn func(w io.Writer) error) *StreamResponse
+	StreamReader(contentType string, reader io.Reader) *StreamResponse
+	SSE(fn func(sse *SSEWriter) error) *SSEResponse
+	ServeFile(path string) *FileResponse
+	ServeFileSystem(fs http.FileSystem, name string) *FileResponse
+	Download(path string, filename string) *FileResponse
+	Attachment(content io.ReadSeeker, filename string, modTime time.Time) *FileResponse
 
 	Decode(v interface{}) error
 	Unmarshal(v interface{}) error
 	UnmarshalYAML(v interface{}) error
+	UnmarshalAuto(v interface{}) error
 	PathVar(key string) string
 	PathVars() map[string]string
 	Input(key string) string
@@ -65,6 +88,26 @@ type Context interface {
 	Int64Input(key string, defaultVal int64) int64
 	Float32Input(key string, defaultVal float32) float32
 	Float64Input(key string, defaultVal float64) float64
+	InputArray(key string) []string
+	InputMap(key string) map[string]string
+	IntArray(key string) []int
+	Int64Array(key string) []int64
+	Float32Array(key string) []float32
+	Float64Array(key string) []float64
+	Query(key string) string
+	QueryWithDefault(key string, defaultVal string) string
+	QueryArray(key string) []string
+	IntQuery(key string, defaultVal int) int
+	Int64Query(key string, defaultVal int64) int64
+	Float32Query(key string, defaultVal float32) float32
+	Float64Query(key string, defaultVal float64) float64
+	PostForm(key string) string
+	PostFormWithDefault(key string, defaultVal string) string
+	PostFormArray(key string) []string
+	IntPostForm(key string, defaultVal int) int
+	Int64PostForm(key string, defaultVal int64) int64
+	Float32PostForm(key string, defaultVal float32) float32
+	Float64PostForm(key string, defaultVal float64) float64
 	IsXMLHTTPRequest() bool
 	AJAX() bool
 	IsJSON() bool
@@ -82,7 +125,10 @@ type Context interface {
 	IsOptions() bool
 	Method() string
 	Body() []byte
+	BodyReader() io.ReadCloser
 	File(key string) (*UploadedFile, error)
+	Files(key string) ([]*UploadedFile, error)
+	Multipart() (*MultipartIterator, error)
 	Set(key string, value interface{})
 	Get(key string) interface{}
 	Context() context.Context
@@ -91,6 +137,7 @@ type Context interface {
 	Request() Request
 	Response() Responsor
 	Container() container.Container
+	Route() Route
 	Validate(validator Validator, jsonResponse bool)
 }
 
@@ -99,6 +146,7 @@ type Request interface {
 	Decode(v interface{}) error
 	Unmarshal(v interface{}) error
 	UnmarshalYAML(v interface{}) error
+	UnmarshalAuto(v interface{}) error
 	PathVar(key string) string
 	PathVars() map[string]string
 	Input(key string) string
@@ -112,7 +160,29 @@ type Request interface {
 	Int64Input(key string, defaultVal int64) int64
 	Float32Input(key string, defaultVal float32) float32
 	Float64Input(key string, defaultVal float64) float64
+	InputArray(key string) []string
+	InputMap(key string) map[string]string
+	IntArray(key string) []int
+	Int64Array(key string) []int64
+	Float32Array(key string) []float32
+	Float64Array(key string) []float64
+	Query(key string) string
+	QueryWithDefault(key string, defaultVal string) string
+	QueryArray(key string) []string
+	IntQuery(key string, defaultVal int) int
+	Int64Query(key string, defaultVal int64) int64
+	Float32Query(key string, defaultVal float32) float32
+	Float64Query(key string, defaultVal float64) float64
+	PostForm(key string) string
+	PostFormWithDefault(key string, defaultVal string) string
+	PostFormArray(key string) []string
+	IntPostForm(key string, defaultVal int) int
+	Int64PostForm(key string, defaultVal int64) int64
+	Float32PostForm(key string, defaultVal float32) float32
+	Float64PostForm(key string, defaultVal float64) float64
 	File(key string) (*UploadedFile, error)
+	Files(key string) ([]*UploadedFile, error)
+	Multipart() (*MultipartIterator, error)
 	IsXMLHTTPRequest() bool
 	AJAX() bool
 	IsJSON() bool
@@ -130,6 +200,7 @@ type Request interface {
 	IsOptions() bool
 	Method() string
 	Body() []byte
+	BodyReader() io.ReadCloser
 	Set(key string, value interface{})
 	Get(key string) interface{}
 
@@ -151,6 +222,13 @@ type Response interface {
 	Code() int
 }
 
+// DeferredResponse is a response whose final status code is only known after it has been sent, such as streams
+type DeferredResponse interface {
+	Response
+	// OnSent register a callback which will be called after the response has been sent
+	OnSent(fn func(resp Response, err error))
+}
+
 // Controller is a interface for controller
 type Controller interface {
 	// Register register routes for a controller
@@ -163,10 +241,21 @@ type Responsor interface {
 	SetCode(code int)
 	ResponseWriter() http.ResponseWriter
 	SetContent(content []byte)
+	GetContent() []byte
 	Header(key string, values ...string)
+	SetHeader(key string, values ...string)
+	AddHeader(key string, values ...string)
+	DelHeader(key string)
+	GetHeader(key string) string
+	Headers() http.Header
 	Cookie(cookie *http.Cookie)
+	ClearCookie(name string, path string)
+	Cookies() []*http.Cookie
 	GetCode() int
 	Flush()
+	FlushHeader()
+	BeforeFlush(fn func())
+	Hijack() (net.Conn, *bufio.ReadWriter, error)
 }
 
 type Decoder interface {
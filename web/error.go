@@ -1,5 +1,12 @@
 package web
 
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrRequestBodyTooLarge is the error when request body exceeds the max body size
+var ErrRequestBodyTooLarge = WrapPlainError(errors.New("request body too large"), http.StatusRequestEntityTooLarge)
 
 // Error is a interface for http error
 type Error interface {
@@ -35,6 +42,11 @@ func (p PlainError) StatusCode() int {
 	return p.code
 }
 
+// Unwrap return the wrapped error
+func (p PlainError) Unwrap() error {
+	return p.err
+}
+
 // JSONError is a error object which implements Error and JSONAble interface
 type JSONError struct {
 	err  error
@@ -54,6 +66,11 @@ func (apiErr JSONError) StatusCode() int {
 	return apiErr.code
 }
 
+// Unwrap return the wrapped error
+func (apiErr JSONError) Unwrap() error {
+	return apiErr.err
+}
+
 func (apiErr JSONError) ToJSON() interface{} {
 	return M{
 		"error": apiErr.err.Error(),
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorResponseBuilder create a response for a mapped error
+type ErrorResponseBuilder func(ctx Context, err error) Response
+
+// ErrorMapping is a registry which maps errors to status codes or responses
+// it's consulted before the ExceptionHandler, rules are matched in registration order through the wrapped error chain
+// errors mapped to a status code are passed on to the ExceptionHandler as a Error carrying the mapped code
+type ErrorMapping struct {
+	lock  sync.RWMutex
+	rules []errorRule
+}
+
+type errorRule struct {
+	// match return the matched error in chain, nil if not matched
+	match   func(err error) error
+	code    int
+	builder ErrorResponseBuilder
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NewErrorMapping create a new ErrorMapping
+func NewErrorMapping() *ErrorMapping {
+	return &ErrorMapping{rules: make([]errorRule, 0)}
+}
+
+// Map map a sentinel error to status code, errors are matched with errors.Is
+func (m *ErrorMapping) Map(target error, code int) *ErrorMapping {
+	return m.add(errorRule{match: sentinelMatcher(target), code: code})
+}
+
+// MapFunc map a sentinel error to a response builder, errors are matched with errors.Is
+func (m *ErrorMapping) MapFunc(target error, builder ErrorResponseBuilder) *ErrorMapping {
+	return m.add(errorRule{match: sentinelMatcher(target), builder: builder})
+}
+
+// MapType map a error type to status code, errors are matched with errors.As
+// the type is specified by a nil pointer to it, for example (*MyError)(nil) for type MyError,
+// (**MyError)(nil) for type *MyError, or (*MyInterface)(nil) for a interface
+func (m *ErrorMapping) MapType(target interface{}, code int) *ErrorMapping {
+	return m.add(errorRule{match: typeMatcher(target), code: code})
+}
+
+// MapTypeFunc map a error type to a response builder, errors are matched with errors.As
+// the builder receives the matched error in chain, see MapType for how to specify the type
+func (m *ErrorMapping) MapTypeFunc(target interface{}, builder ErrorResponseBuilder) *ErrorMapping {
+	return m.add(errorRule{match: typeMatcher(target), builder: builder})
+}
+
+func (m *ErrorMapping) add(rule errorRule) *ErrorMapping {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.rules = append(m.rules, rule)
+	return m
+}
+
+// lookup find the first rule matches err, returns the matched error in chain
+func (m *ErrorMapping) lookup(err error) (errorRule, error, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, rule := range m.rules {
+		if matched := rule.match(err); matched != nil {
+			return rule, matched, true
+		}
+	}
+
+	return errorRule{}, nil, false
+}
+
+func sentinelMatcher(target error) func(err error) error {
+	return func(err error) error {
+		if errors.Is(err, target) {
+			return err
+		}
+
+		return nil
+	}
+}
+
+func typeMatcher(target interface{}) func(err error) error {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("error type must be specified by a nil pointer to it, got %v", typ))
+	}
+
+	typ = typ.Elem()
+	if typ.Kind() != reflect.Interface && !typ.Implements(errorType) {
+		panic(fmt.Sprintf("%v does not implement error", typ))
+	}
+
+	return func(err error) error {
+		ptr := reflect.New(typ)
+		if !errors.As(err, ptr.Interface()) {
+			return nil
+		}
+
+		if matched, ok := ptr.Elem().Interface().(error); ok {
+			return matched
+		}
+
+		return err
+	}
+}
+
+// statusError overrides the status code of a error and keeps the error chain
+type statusError struct {
+	err  error
+	code int
+}
+
+func (s statusError) Error() string {
+	return s.err.Error()
+}
+
+func (s statusError) StatusCode() int {
+	return s.code
+}
+
+func (s statusError) Unwrap() error {
+	return s.err
+}
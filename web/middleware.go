@@ -22,19 +22,28 @@ func NewRequestMiddleware() RequestMiddleware {
 }
 
 // AccessLog create a access log middleware
+// for DeferredResponse like streams, the log is written after the response has been sent
 func (rm RequestMiddleware) AccessLog(logger Log) HandlerDecorator {
 	return func(handler Handler) Handler {
 		return func(ctx Context) Response {
 			startTs := time.Now()
 			resp := handler(ctx)
 
-			logger.Debugf(
-				"%s %s [%d] [%.4fms]",
-				ctx.Method(),
-				ctx.Request().Raw().URL.String(),
-				resp.Code(),
-				time.Now().Sub(startTs).Seconds()*1000,
-			)
+			log := func(resp Response, _ error) {
+				logger.Debugf(
+					"%s %s [%d] [%.4fms]",
+					ctx.Method(),
+					ctx.Request().Raw().URL.String(),
+					resp.Code(),
+					time.Now().Sub(startTs).Seconds()*1000,
+				)
+			}
+
+			if deferred, ok := resp.(DeferredResponse); ok {
+				deferred.OnSent(log)
+			} else {
+				log(resp, nil)
+			}
 
 			return resp
 		}
@@ -51,19 +60,28 @@ type CustomAccessLog struct {
 }
 
 // CustomAccessLog create a custom access log handler middleware
+// for DeferredResponse like streams, the log is created after the response has been sent
 func (rm RequestMiddleware) CustomAccessLog(fn func(cal CustomAccessLog)) HandlerDecorator {
 	return func(handler Handler) Handler {
 		return func(ctx Context) Response {
 			startTs := time.Now()
 			resp := handler(ctx)
 
-			go fn(CustomAccessLog{
-				Context:      ctx,
-				Method:       ctx.Method(),
-				URL:          ctx.Request().Raw().URL.String(),
-				ResponseCode: resp.Code(),
-				Elapse:       time.Now().Sub(startTs),
-			})
+			log := func(resp Response, _ error) {
+				go fn(CustomAccessLog{
+					Context:      ctx,
+					Method:       ctx.Method(),
+					URL:          ctx.Request().Raw().URL.String(),
+					ResponseCode: resp.Code(),
+					Elapse:       time.Now().Sub(startTs),
+				})
+			}
+
+			if deferred, ok := resp.(DeferredResponse); ok {
+				deferred.OnSent(log)
+			} else {
+				log(resp, nil)
+			}
 
 			return resp
 		}
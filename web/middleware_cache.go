@@ -0,0 +1,347 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache status values of the cache status header
+const (
+	CacheStatusHit    = "HIT"
+	CacheStatusMiss   = "MISS"
+	CacheStatusStale  = "STALE"
+	CacheStatusBypass = "BYPASS"
+)
+
+// noCacheKey is the key set to context by NoCache middleware
+const noCacheKey = "web.no_cache"
+
+// CacheOptions is the options for Cache middleware
+type CacheOptions struct {
+	// Store is the cache storage, a MemoryCacheStore with 1000 entries is used if it's nil
+	Store CacheStore
+	// TTL is the default time to live of responses, max-age/s-maxage in response Cache-Control takes precedence
+	TTL time.Duration
+	// StaleWhileRevalidate is the duration a expired response can be served while it's refreshed in background
+	StaleWhileRevalidate time.Duration
+	// VaryHeaders is the request headers used as part of the cache key
+	VaryHeaders []string
+	// StatusHeader is the response header for cache status, empty means not set
+	StatusHeader string
+	// Skip decides whether the request should bypass the cache
+	Skip func(ctx Context) bool
+}
+
+// DefaultCacheOptions create a default CacheOptions which caches responses for ttl in memory
+func DefaultCacheOptions(ttl time.Duration) CacheOptions {
+	return CacheOptions{
+		TTL:          ttl,
+		VaryHeaders:  []string{"Accept", "Accept-Encoding"},
+		StatusHeader: "X-Cache",
+	}
+}
+
+// Cache create a server side response cache middleware
+// only successful buffered responses of GET and HEAD requests are cached, responses with cookies or
+// Cache-Control of no-store, no-cache or private are not cached, streaming responses are never cached
+// requests with Cache-Control: no-cache skip the cache lookup and refresh the cached response
+// requests with Authorization or Cookie never use cached responses, and their responses are only cached
+// when the response explicitly allows shared caching by public, s-maxage or must-revalidate(RFC 9111 section 3.5)
+// stale responses are refreshed in background by the handler chain with a copy of current request
+func (rm RequestMiddleware) Cache(opts CacheOptions) HandlerDecorator {
+	if opts.Store == nil {
+		opts.Store = NewMemoryCacheStore(1000)
+	}
+
+	revalidating := &sync.Map{}
+
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			method := ctx.Method()
+			if (method != http.MethodGet && method != http.MethodHead) || (opts.Skip != nil && opts.Skip(ctx)) {
+				return handler(ctx)
+			}
+
+			reqCacheControl := parseCacheControl(ctx.Header("Cache-Control"))
+			if _, ok := reqCacheControl["no-store"]; ok {
+				setCacheStatus(ctx.Response(), opts, CacheStatusBypass)
+				return handler(ctx)
+			}
+
+			key := cacheKey(ctx, opts.VaryHeaders)
+			if hasCredentials(ctx) {
+				setCacheStatus(ctx.Response(), opts, CacheStatusBypass)
+				storeOnFlush(ctx, key, opts, true)
+				return handler(ctx)
+			}
+
+			if _, refresh := reqCacheControl["no-cache"]; !refresh {
+				if entry, ok := opts.Store.Get(key); ok {
+					now := time.Now()
+					if entry.Fresh(now) {
+						return serveCacheEntry(ctx, entry, opts, CacheStatusHit)
+					}
+
+					if entry.Stale(now) {
+						if _, loaded := revalidating.LoadOrStore(key, true); !loaded {
+							// the copy of request must be created in current goroutine
+							if revalidate := revalidateCache(ctx, handler, key, opts); revalidate != nil {
+								go func() {
+									defer revalidating.Delete(key)
+									revalidate()
+								}()
+							} else {
+								revalidating.Delete(key)
+							}
+						}
+
+						return serveCacheEntry(ctx, entry, opts, CacheStatusStale)
+					}
+				}
+			}
+
+			setCacheStatus(ctx.Response(), opts, CacheStatusMiss)
+			storeOnFlush(ctx, key, opts, false)
+
+			return handler(ctx)
+		}
+	}
+}
+
+// NoCache create a middleware which prevents responses of the route from being cached by Cache middleware
+func (rm RequestMiddleware) NoCache() HandlerDecorator {
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			ctx.Set(noCacheKey, true)
+			return handler(ctx)
+		}
+	}
+}
+
+// hasCredentials return whether the request carries credentials, responses to it may be personalized
+func hasCredentials(ctx Context) bool {
+	return ctx.Header("Authorization") != "" || ctx.Header("Cookie") != ""
+}
+
+// storeOnFlush save the response to cache when it's flushed
+// the callback is registered before calling handler, so it sees the response before other middlewares modify it
+// if explicitOnly is true, the response is only cached when it explicitly allows shared caching
+func storeOnFlush(ctx Context, key string, opts CacheOptions, explicitOnly bool) {
+	response := ctx.Response()
+	response.BeforeFlush(func() {
+		if noCache, _ := ctx.Get(noCacheKey).(bool); noCache {
+			return
+		}
+
+		if response.GetCode() != http.StatusOK || len(response.Cookies()) > 0 {
+			return
+		}
+
+		if explicitOnly && !sharedCacheAllowed(response) {
+			return
+		}
+
+		ttl, ok := responseTTL(response, opts.TTL)
+		if !ok || ttl <= 0 {
+			return
+		}
+
+		header := make(http.Header)
+		for k, v := range response.Headers() {
+			if k == http.CanonicalHeaderKey(opts.StatusHeader) {
+				continue
+			}
+
+			header[k] = append([]string(nil), v...)
+		}
+
+		now := time.Now()
+		opts.Store.Set(key, &CacheEntry{
+			Code:       response.GetCode(),
+			Header:     header,
+			Body:       append([]byte(nil), response.GetContent()...),
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(ttl),
+			StaleUntil: now.Add(ttl + opts.StaleWhileRevalidate),
+		}, ttl+opts.StaleWhileRevalidate)
+	})
+}
+
+// revalidateCache create a function which refreshes the cached response in background with handler
+// the function works on a copy of current request with values stored by outer decorators, nil is returned if it can't be copied
+func revalidateCache(ctx Context, handler Handler, key string, opts CacheOptions) func() {
+	wtx, ok := ctx.(*webContext)
+	if !ok {
+		return nil
+	}
+
+	req, ok := wtx.request.(*httpRequest)
+	if !ok {
+		return nil
+	}
+
+	childReq := req.fork(detachedContext{req.r.Context()})
+	bgCtx := &webContext{
+		cc:        wtx.cc,
+		conf:      wtx.conf,
+		responsor: NewResponseCreator(&discardResponseWriter{header: make(http.Header)}),
+		request:   childReq,
+		route:     wtx.route,
+		router:    wtx.router,
+	}
+
+	return func() {
+		defer req.release(childReq)
+		defer func() {
+			if err := recover(); err != nil && wtx.router != nil {
+				wtx.router.reportPanic(bgCtx, err, debug.Stack())
+			}
+		}()
+
+		storeOnFlush(bgCtx, key, opts, false)
+		if resp := handler(bgCtx); resp != nil {
+			if err := resp.Send(); err != nil && wtx.router != nil && wtx.router.logger != nil {
+				wtx.router.logger.Errorf("revalidate cache failed: %v", err)
+			}
+		}
+	}
+}
+
+// serveCacheEntry write the cached response to ctx
+func serveCacheEntry(ctx Context, entry *CacheEntry, opts CacheOptions, status string) Response {
+	response := ctx.Response()
+	for k, v := range entry.Header {
+		response.SetHeader(k, v...)
+	}
+
+	response.SetHeader("Age", strconv.Itoa(int(time.Since(entry.CreatedAt).Seconds())))
+	setCacheStatus(response, opts, status)
+
+	response.SetCode(entry.Code)
+	response.SetContent(entry.Body)
+
+	return ctx.Plain()
+}
+
+func setCacheStatus(response Responsor, opts CacheOptions, status string) {
+	if opts.StatusHeader != "" {
+		response.SetHeader(opts.StatusHeader, status)
+	}
+}
+
+// cacheKey create the cache key from method, host, path, query and vary headers
+func cacheKey(ctx Context, varyHeaders []string) string {
+	raw := ctx.Request().Raw()
+
+	var sb strings.Builder
+	sb.WriteString(raw.Method)
+	sb.WriteString(" ")
+	sb.WriteString(raw.Host)
+	sb.WriteString(raw.URL.Path)
+	sb.WriteString("?")
+	sb.WriteString(raw.URL.Query().Encode())
+
+	headers := append([]string(nil), varyHeaders...)
+	sort.Strings(headers)
+	for _, h := range headers {
+		sb.WriteString(fmt.Sprintf("\n%s: %s", http.CanonicalHeaderKey(h), strings.Join(raw.Header.Values(h), ",")))
+	}
+
+	return sb.String()
+}
+
+// responseTTL return the ttl of response according to Cache-Control, false means it should not be cached
+func responseTTL(response Responsor, defaultTTL time.Duration) (time.Duration, bool) {
+	if strings.TrimSpace(response.GetHeader("Vary")) == "*" {
+		return 0, false
+	}
+
+	directives := parseCacheControl(response.GetHeader("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0, false
+		}
+	}
+
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+
+	return defaultTTL, true
+}
+
+// sharedCacheAllowed return whether the response explicitly allows to be stored by shared caches
+func sharedCacheAllowed(response Responsor) bool {
+	directives := parseCacheControl(response.GetHeader("Cache-Control"))
+	for _, d := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := directives[d]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCacheControl parse Cache-Control header to directives
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.SplitN(item, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			directives[name] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			directives[name] = ""
+		}
+	}
+
+	return directives
+}
+
+// discardResponseWriter is a http.ResponseWriter which discards everything written to it
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
+
+// detachedContext keeps the values of parent context but is never cancelled
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+type cacheTestRequest struct {
+	header http.Header
+	status string
+	body   string
+}
+
+func TestCacheCredentials(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cacheControl string
+		requests     []cacheTestRequest
+	}{
+		{
+			name: "anonymous requests are cached",
+			requests: []cacheTestRequest{
+				{status: CacheStatusMiss, body: "1 "},
+				{status: CacheStatusHit, body: "1 "},
+			},
+		},
+		{
+			name: "authorized requests bypass cached responses",
+			requests: []cacheTestRequest{
+				{status: CacheStatusMiss, body: "1 "},
+				{header: http.Header{"Authorization": {"Bearer alice"}}, status: CacheStatusBypass, body: "2 Bearer alice"},
+				{header: http.Header{"Cookie": {"session=bob"}}, status: CacheStatusBypass, body: "3 session=bob"},
+			},
+		},
+		{
+			name: "responses to authorized requests are not cached by default",
+			requests: []cacheTestRequest{
+				{header: http.Header{"Authorization": {"Bearer alice"}}, status: CacheStatusBypass, body: "1 Bearer alice"},
+				{status: CacheStatusMiss, body: "2 "},
+			},
+		},
+		{
+			name: "responses to requests with cookies are not cached by default",
+			requests: []cacheTestRequest{
+				{header: http.Header{"Cookie": {"session=bob"}}, status: CacheStatusBypass, body: "1 session=bob"},
+				{status: CacheStatusMiss, body: "2 "},
+			},
+		},
+		{
+			name:         "public responses to authorized requests are cached",
+			cacheControl: "public, max-age=60",
+			requests: []cacheTestRequest{
+				{header: http.Header{"Authorization": {"Bearer alice"}}, status: CacheStatusBypass, body: "1 Bearer alice"},
+				{status: CacheStatusHit, body: "1 Bearer alice"},
+			},
+		},
+		{
+			name:         "s-maxage responses to authorized requests are cached",
+			cacheControl: "s-maxage=60",
+			requests: []cacheTestRequest{
+				{header: http.Header{"Authorization": {"Bearer alice"}}, status: CacheStatusBypass, body: "1 Bearer alice"},
+				{status: CacheStatusHit, body: "1 Bearer alice"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		var calls int64
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", func(ctx Context) string {
+				if tc.cacheControl != "" {
+					ctx.Response().SetHeader("Cache-Control", tc.cacheControl)
+				}
+
+				credential := ctx.Header("Authorization") + ctx.Header("Cookie")
+				return fmt.Sprintf("%d %s", atomic.AddInt64(&calls, 1), credential)
+			})
+		}, NewRequestMiddleware().Cache(DefaultCacheOptions(time.Minute)))
+
+		for i, r := range tc.requests {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range r.header {
+				req.Header[k] = v
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if status := w.Header().Get("X-Cache"); status != r.status {
+				t.Errorf("%s: request %d: expect cache status %s, got %s", tc.name, i, r.status, status)
+			}
+
+			if w.Body.String() != r.body {
+				t.Errorf("%s: request %d: expect body %q, got %q", tc.name, i, r.body, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	opts := DefaultCacheOptions(10 * time.Millisecond)
+	opts.StaleWhileRevalidate = time.Minute
+
+	var calls int64
+	revalidated := make(chan struct{}, 1)
+	router := NewRouter(container.New(), DefaultConfig())
+	router.Group("", func(router *Router) {
+		router.Get("/", func(ctx Context) string {
+			n := atomic.AddInt64(&calls, 1)
+			if n > 1 {
+				defer func() {
+					select {
+					case revalidated <- struct{}{}:
+					default:
+					}
+				}()
+			}
+
+			return fmt.Sprintf("%d %v", n, ctx.Get("tenant"))
+		})
+	}, NewRequestMiddleware().Cache(opts), func(handler Handler) Handler {
+		// outer decorator runs before the cache, its values must be available for revalidation
+		return func(ctx Context) Response {
+			ctx.Set("tenant", "acme")
+			return handler(ctx)
+		}
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	if w := request(); w.Body.String() != "1 acme" {
+		t.Fatalf("expect body %q, got %q", "1 acme", w.Body.String())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if w := request(); w.Header().Get("X-Cache") != CacheStatusStale || w.Body.String() != "1 acme" {
+		t.Fatalf("expect stale response, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		t.Fatal("expect the response to be revalidated in background")
+	}
+
+	// the entry is stored after the response flushed, wait for it
+	for i := 0; i < 100; i++ {
+		if w := request(); w.Body.String() == "2 acme" {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Errorf("expect revalidated response with values of outer decorators")
+}
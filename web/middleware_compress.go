@@ -0,0 +1,190 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+)
+
+// Content codings supported by Compress middleware
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// CompressOptions is the options for Compress middleware
+type CompressOptions struct {
+	// Encodings is the supported content codings in preference order
+	Encodings []string
+	// Level is the compression level, 0 means the default level of each coding
+	// it's clamped to the valid range of each coding, eg. 11 is the best level for br but 9 for gzip
+	Level int
+	// Levels is the compression levels for specific codings, which overrides Level
+	Levels map[string]int
+	// MinSize is the min response body size for compressing, smaller responses are sent as it is
+	MinSize int
+	// ContentTypes is the media types to compress, wildcards like text/* are supported, empty means all types
+	ContentTypes []string
+}
+
+// DefaultCompressOptions create a default CompressOptions which compress text based responses larger than 1KB
+func DefaultCompressOptions() CompressOptions {
+	return CompressOptions{
+		Encodings: []string{EncodingBrotli, EncodingGzip, EncodingDeflate},
+		MinSize:   1024,
+		ContentTypes: []string{
+			"text/*",
+			"application/json",
+			"application/problem+json",
+			"application/javascript",
+			"application/xml",
+			"application/yaml",
+			"application/x-yaml",
+			"application/x-ndjson",
+			"image/svg+xml",
+		},
+	}
+}
+
+// Compress create a response compression middleware
+// only buffered responses are compressed, streaming responses(stream, SSE, file) and responses
+// already have a Content-Encoding are sent as it is
+func (rm RequestMiddleware) Compress(opts CompressOptions) HandlerDecorator {
+	if len(opts.Encodings) == 0 {
+		opts.Encodings = DefaultCompressOptions().Encodings
+	}
+
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			resp := handler(ctx)
+
+			// compression is postponed to the end of flushing, so that other middlewares see the original content
+			response := ctx.Response()
+			response.BeforeFlush(func() {
+				response.BeforeFlush(func() {
+					compressResponse(response, ctx.Header("Accept-Encoding"), opts)
+				})
+			})
+
+			return resp
+		}
+	}
+}
+
+// compressResponse compress the buffered content of response if possible
+func compressResponse(response Responsor, acceptEncoding string, opts CompressOptions) {
+	code := response.GetCode()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		return
+	}
+
+	if response.GetHeader("Content-Encoding") != "" || strings.Contains(response.GetHeader("Cache-Control"), "no-transform") {
+		return
+	}
+
+	content := response.GetContent()
+	contentType := response.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+
+	if !mimeTypeAllowed(contentType, opts.ContentTypes) {
+		return
+	}
+
+	addVary(response, "Accept-Encoding")
+	if len(content) < opts.MinSize || len(content) == 0 {
+		return
+	}
+
+	encoding, ok := NegotiateEncoding(acceptEncoding, opts.Encodings)
+	if !ok {
+		return
+	}
+
+	level := opts.Level
+	if l, ok := opts.Levels[encoding]; ok {
+		level = l
+	}
+
+	compressed, err := compress(encoding, level, content)
+	if err != nil || len(compressed) >= len(content) {
+		return
+	}
+
+	response.SetContent(compressed)
+	response.SetHeader("Content-Encoding", encoding)
+	response.DelHeader("Content-Length")
+
+	// the compressed content is not byte-for-byte identical to the original representation
+	if etag := response.GetHeader("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		response.SetHeader("ETag", "W/"+etag)
+	}
+}
+
+// compress encode content with content coding, level is clamped to the valid range of the coding
+func compress(encoding string, level int, content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+
+	switch encoding {
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, clampLevel(level, brotli.BestSpeed, brotli.BestCompression, brotli.DefaultCompression))
+	case EncodingGzip:
+		w, err = gzip.NewWriterLevel(&buf, clampLevel(level, gzip.HuffmanOnly, gzip.BestCompression, gzip.DefaultCompression))
+	case EncodingDeflate:
+		// deflate content coding is the zlib format(RFC 1950), not raw deflate
+		w, err = zlib.NewWriterLevel(&buf, clampLevel(level, zlib.HuffmanOnly, zlib.BestCompression, zlib.DefaultCompression))
+	default:
+		return nil, errors.Errorf("unsupported content coding %s", encoding)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// clampLevel limit level to [min, max], 0 means the default level
+func clampLevel(level, min, max, defaultLevel int) int {
+	switch {
+	case level == 0:
+		return defaultLevel
+	case level < min:
+		return min
+	case level > max:
+		return max
+	default:
+		return level
+	}
+}
+
+// addVary add a header name to Vary header if it's not present
+func addVary(response Responsor, header string) {
+	for _, value := range response.Headers()["Vary"] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v == "*" || strings.EqualFold(v, header) {
+				return
+			}
+		}
+	}
+
+	response.AddHeader("Vary", header)
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/mylxsw/container"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		cacheControl   string
+		opts           func(opts *CompressOptions)
+		encoding       string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "text/plain", body: large, encoding: EncodingGzip},
+		{name: "deflate", acceptEncoding: "deflate", contentType: "application/json", body: large, encoding: EncodingDeflate},
+		{name: "brotli preferred", acceptEncoding: "gzip, deflate, br", contentType: "text/html", body: large, encoding: EncodingBrotli},
+		{name: "quality", acceptEncoding: "br;q=0.5, gzip", contentType: "text/html", body: large, encoding: EncodingGzip},
+		{name: "yaml", acceptEncoding: "gzip", contentType: "application/yaml", body: large, encoding: EncodingGzip},
+		{name: "no accept encoding", contentType: "text/plain", body: large},
+		{name: "too small", acceptEncoding: "gzip", contentType: "text/plain", body: "hello"},
+		{name: "content type not allowed", acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{name: "no-transform", acceptEncoding: "gzip", contentType: "text/plain", body: large, cacheControl: "no-transform"},
+		{
+			name:           "level above the max of gzip",
+			acceptEncoding: "gzip",
+			contentType:    "text/plain",
+			body:           large,
+			opts:           func(opts *CompressOptions) { opts.Level = 11 },
+			encoding:       EncodingGzip,
+		},
+		{
+			name:           "level of coding",
+			acceptEncoding: "br",
+			contentType:    "text/plain",
+			body:           large,
+			opts:           func(opts *CompressOptions) { opts.Levels = map[string]int{EncodingBrotli: 11} },
+			encoding:       EncodingBrotli,
+		},
+	}
+
+	for _, tc := range testCases {
+		opts := DefaultCompressOptions()
+		if tc.opts != nil {
+			tc.opts(&opts)
+		}
+
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", func(ctx Context) Response {
+				ctx.Response().SetCode(http.StatusOK)
+				ctx.Response().SetHeader("Content-Type", tc.contentType)
+				if tc.cacheControl != "" {
+					ctx.Response().SetHeader("Cache-Control", tc.cacheControl)
+				}
+
+				ctx.Response().SetContent([]byte(tc.body))
+				return ctx.Plain()
+			})
+		}, NewRequestMiddleware().Compress(opts))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != tc.encoding {
+			t.Errorf("%s: expect encoding %q, got %q", tc.name, tc.encoding, encoding)
+			continue
+		}
+
+		body, err := decompress(tc.encoding, w.Body)
+		if err != nil {
+			t.Errorf("%s: decompress failed: %v", tc.name, err)
+			continue
+		}
+
+		if body != tc.body {
+			t.Errorf("%s: expect body of %d bytes, got %d bytes", tc.name, len(tc.body), len(body))
+		}
+	}
+}
+
+func decompress(encoding string, body io.Reader) (string, error) {
+	var reader io.Reader
+	var err error
+
+	switch encoding {
+	case EncodingGzip:
+		reader, err = gzip.NewReader(body)
+	case EncodingDeflate:
+		reader, err = zlib.NewReader(body)
+	case EncodingBrotli:
+		reader = brotli.NewReader(body)
+	default:
+		reader = body
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	return string(data), err
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrServiceOverloaded is the error when a request is shed by concurrency limiter
+var ErrServiceOverloaded = WrapPlainError(errors.New("service overloaded"), http.StatusServiceUnavailable)
+
+// ConcurrencyLimiter caps the in-flight requests, requests over the limit wait in a bounded queue
+type ConcurrencyLimiter struct {
+	limit    int
+	maxQueue int
+	maxWait  time.Duration
+	slots    chan struct{}
+
+	waiting  int64
+	rejected uint64
+}
+
+// ConcurrencyStats is the utilization of a ConcurrencyLimiter
+type ConcurrencyStats struct {
+	Limit    int `json:"limit"`
+	InFlight int `json:"in_flight"`
+	MaxQueue int `json:"max_queue"`
+	Waiting  int `json:"waiting"`
+	// Rejected is the count of requests shed since the limiter is created
+	Rejected uint64 `json:"rejected"`
+	// Utilization is InFlight / Limit
+	Utilization float64 `json:"utilization"`
+}
+
+// NewConcurrencyLimiter create a ConcurrencyLimiter allows limit requests in flight
+// at most maxQueue requests wait for at most maxWait, maxQueue 0 means rejecting immediately when it's full
+func NewConcurrencyLimiter(limit int, maxQueue int, maxWait time.Duration) *ConcurrencyLimiter {
+	if limit <= 0 {
+		panic("concurrency limit must be greater than 0")
+	}
+
+	return &ConcurrencyLimiter{
+		limit:    limit,
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+		slots:    make(chan struct{}, limit),
+	}
+}
+
+// Acquire take a slot, it returns ErrServiceOverloaded if the queue is full or waiting timeout,
+// or the error of ctx if it's done while waiting
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if atomic.AddInt64(&l.waiting, 1) > int64(l.maxQueue) {
+		atomic.AddInt64(&l.waiting, -1)
+		atomic.AddUint64(&l.rejected, 1)
+		return ErrServiceOverloaded
+	}
+	defer atomic.AddInt64(&l.waiting, -1)
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		atomic.AddUint64(&l.rejected, 1)
+		return ErrServiceOverloaded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release give back a slot taken by Acquire
+func (l *ConcurrencyLimiter) Release() {
+	<-l.slots
+}
+
+// Stats return the current utilization
+func (l *ConcurrencyLimiter) Stats() ConcurrencyStats {
+	inFlight := len(l.slots)
+	return ConcurrencyStats{
+		Limit:       l.limit,
+		InFlight:    inFlight,
+		MaxQueue:    l.maxQueue,
+		Waiting:     int(atomic.LoadInt64(&l.waiting)),
+		Rejected:    atomic.LoadUint64(&l.rejected),
+		Utilization: float64(inFlight) / float64(l.limit),
+	}
+}
+
+// ConcurrencyLimit create a middleware which limits in-flight requests with limiter
+// use it as a router decorator for a global limit, or as a route decorator with a separate limiter for a per-route limit
+// the slot is released when the request finished, so responses sent after the handler returned(eg. streams) still occupy it
+// requests shed are handled by exception pipeline with ErrServiceOverloaded
+func (rm RequestMiddleware) ConcurrencyLimit(limiter *ConcurrencyLimiter) HandlerDecorator {
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			if err := limiter.Acquire(ctx.Context()); err != nil {
+				if err == ErrServiceOverloaded {
+					ctx.Response().SetHeader("Retry-After", "1")
+					return exceptionResponse(ctx, err)
+				}
+
+				// the request is cancelled while waiting
+				return exceptionResponse(ctx, WrapPlainError(err, http.StatusServiceUnavailable))
+			}
+
+			if req, ok := ctx.Request().(*httpRequest); ok {
+				req.closeOnCleanup(limiterSlot{limiter: limiter})
+				return handler(ctx)
+			}
+
+			defer limiter.Release()
+			return handler(ctx)
+		}
+	}
+}
+
+// limiterSlot is a slot taken from limiter, it's released when closed
+type limiterSlot struct {
+	limiter *ConcurrencyLimiter
+}
+
+func (slot limiterSlot) Close() error {
+	slot.limiter.Release()
+	return nil
+}
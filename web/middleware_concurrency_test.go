@@ -0,0 +1,148 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+func TestConcurrencyLimitRelease(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler func(limiter *ConcurrencyLimiter) interface{}
+		code    int
+		// inFlight is the in-flight requests seen by the handler while sending
+		inFlight int
+	}{
+		{
+			name: "buffered response",
+			handler: func(limiter *ConcurrencyLimiter) interface{} {
+				return func(ctx Context) Response {
+					return ctx.JSON(M{"in_flight": limiter.Stats().InFlight})
+				}
+			},
+			code:     http.StatusOK,
+			inFlight: 1,
+		},
+		{
+			name: "stream response",
+			handler: func(limiter *ConcurrencyLimiter) interface{} {
+				return func(ctx Context) Response {
+					return ctx.Stream("text/plain", func(w io.Writer) error {
+						if limiter.Stats().InFlight != 1 {
+							return io.ErrUnexpectedEOF
+						}
+
+						_, err := w.Write([]byte("ok"))
+						return err
+					})
+				}
+			},
+			code:     http.StatusOK,
+			inFlight: 1,
+		},
+		{
+			name: "panic",
+			handler: func(limiter *ConcurrencyLimiter) interface{} {
+				return func(ctx Context) Response {
+					panic("boom")
+				}
+			},
+			code: http.StatusInternalServerError,
+		},
+		{
+			name: "stream abandoned by timeout",
+			handler: func(limiter *ConcurrencyLimiter) interface{} {
+				return func(ctx Context) Response {
+					<-ctx.Context().Done()
+					return ctx.Stream("text/plain", func(w io.Writer) error {
+						_, err := w.Write([]byte("late"))
+						return err
+					})
+				}
+			},
+			code: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		limiter := NewConcurrencyLimiter(1, 0, 0)
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", tc.handler(limiter)).WithTimeout(20 * time.Millisecond)
+		}, NewRequestMiddleware().ConcurrencyLimit(limiter))
+
+		// all slots must be released, so the second request is not rejected
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tc.code {
+				t.Errorf("%s: request %d: expect code %d, got %d", tc.name, i, tc.code, w.Code)
+			}
+		}
+
+		// abandoned handlers release slots after they finished
+		for i := 0; i < 100 && limiter.Stats().InFlight > 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		if inFlight := limiter.Stats().InFlight; inFlight != 0 {
+			t.Errorf("%s: expect all slots released, got %d in flight", tc.name, inFlight)
+		}
+	}
+}
+
+func TestConcurrencyLimitReject(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 0, 0)
+	router := NewRouter(container.New(), DefaultConfig())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	logged := make(chan CustomAccessLog, 2)
+	router.Group("", func(router *Router) {
+		router.Get("/slow", func() string {
+			close(started)
+			<-release
+			return "done"
+		})
+		router.Get("/fast", func() string { return "fast" })
+	}, NewRequestMiddleware().ConcurrencyLimit(limiter), NewRequestMiddleware().CustomAccessLog(func(cal CustomAccessLog) {
+		logged <- cal
+	}))
+
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	close(release)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("expect Retry-After header")
+	}
+
+	if stats := limiter.Stats(); stats.Rejected != 1 {
+		t.Errorf("expect 1 rejected request, got %d", stats.Rejected)
+	}
+
+	// rejected requests are visible to outer decorators
+	for i := 0; i < 2; i++ {
+		select {
+		case cal := <-logged:
+			if cal.URL == "/fast" && cal.ResponseCode != http.StatusServiceUnavailable {
+				t.Errorf("expect access log code %d, got %d", http.StatusServiceUnavailable, cal.ResponseCode)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expect access logs")
+		}
+	}
+}
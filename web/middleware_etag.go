@@ -0,0 +1,158 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag create a middleware which generates ETag from the buffered response body and handles conditional GET requests
+// weak decides whether a weak ETag(W/"...") is generated, ETag and Last-Modified set by handlers are used as it is
+// If-None-Match and If-Modified-Since lead to 304 Not Modified, If-Match and If-Unmodified-Since lead to 412 Precondition Failed
+// only GET and HEAD requests are handled, handlers of other methods should use CheckPreconditions before modifying resources
+func (rm RequestMiddleware) ETag(weak bool) HandlerDecorator {
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			resp := handler(ctx)
+
+			method := ctx.Method()
+			if method != http.MethodGet && method != http.MethodHead {
+				return resp
+			}
+
+			response := ctx.Response()
+			response.BeforeFlush(func() {
+				code := response.GetCode()
+				if code < http.StatusOK || code >= http.StatusMultipleChoices {
+					return
+				}
+
+				etag := response.GetHeader("ETag")
+				if etag == "" {
+					etag = generateETag(response.GetContent(), weak)
+					response.SetHeader("ETag", etag)
+				}
+
+				lastModified, _ := http.ParseTime(response.GetHeader("Last-Modified"))
+				if status := evaluatePreconditions(ctx.Request().Raw(), etag, lastModified); status != 0 {
+					writePreconditionStatus(response, status)
+				}
+			})
+
+			return resp
+		}
+	}
+}
+
+// CheckPreconditions set ETag and Last-Modified headers and evaluate conditional request headers against them
+// it returns a 304 or 412 response if the request is not needed to be processed, otherwise nil
+// handlers can call it before doing expensive work, etag or lastModified can be empty if unknown
+//
+//	if resp := web.CheckPreconditions(ctx, article.ETag(), article.UpdatedAt); resp != nil {
+//	    return resp
+//	}
+func CheckPreconditions(ctx Context, etag string, lastModified time.Time) Response {
+	if etag != "" {
+		ctx.Response().SetHeader("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		ctx.Response().SetHeader("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	status := evaluatePreconditions(ctx.Request().Raw(), etag, lastModified)
+	if status == 0 {
+		return nil
+	}
+
+	writePreconditionStatus(ctx.Response(), status)
+	return ctx.Plain()
+}
+
+// generateETag create a ETag from content
+func generateETag(content []byte, weak bool) string {
+	sum := sha256.Sum256(content)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+
+	return etag
+}
+
+// evaluatePreconditions evaluate conditional request headers as RFC 7232 section 6
+// it returns 304, 412 or 0 if the request should be processed normally
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagListMatch(ifMatch, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatch(ifNoneMatch, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// etagListMatch return whether etag matches one of the entity tags in header
+// weak comparison ignores the W/ prefix, strong comparison requires both are strong
+func etagListMatch(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if etag == "" {
+			continue
+		}
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+
+			continue
+		}
+
+		if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writePreconditionStatus change the buffered response to 304 or 412
+func writePreconditionStatus(response Responsor, status int) {
+	response.SetCode(status)
+	response.DelHeader("Content-Length")
+
+	if status == http.StatusNotModified {
+		response.DelHeader("Content-Type")
+		response.SetContent(nil)
+		return
+	}
+
+	response.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	response.SetContent([]byte(http.StatusText(status)))
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+func TestETag(t *testing.T) {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	etag := generateETag([]byte("hello"), false)
+
+	testCases := []struct {
+		name   string
+		weak   bool
+		method string
+		path   string
+		header map[string]string
+		code   int
+		body   string
+		etag   string
+	}{
+		{name: "generated", path: "/", code: http.StatusOK, body: "hello", etag: etag},
+		{name: "generated weak", weak: true, path: "/", code: http.StatusOK, body: "hello", etag: "W/" + etag},
+		{name: "if-none-match", path: "/", header: map[string]string{"If-None-Match": etag}, code: http.StatusNotModified, etag: etag},
+		{name: "if-none-match weak comparison", path: "/", header: map[string]string{"If-None-Match": "W/" + etag}, code: http.StatusNotModified, etag: etag},
+		{name: "if-none-match any", path: "/", header: map[string]string{"If-None-Match": "*"}, code: http.StatusNotModified, etag: etag},
+		{name: "if-none-match not matched", path: "/", header: map[string]string{"If-None-Match": `"other"`}, code: http.StatusOK, body: "hello", etag: etag},
+		{name: "if-match", path: "/", header: map[string]string{"If-Match": etag}, code: http.StatusOK, body: "hello", etag: etag},
+		{name: "if-match not matched", path: "/", header: map[string]string{"If-Match": `"other"`}, code: http.StatusPreconditionFailed, body: "Precondition Failed", etag: etag},
+		{name: "if-match strong comparison", weak: true, path: "/", header: map[string]string{"If-Match": "W/" + etag}, code: http.StatusPreconditionFailed, body: "Precondition Failed", etag: "W/" + etag},
+		{name: "handler etag", path: "/custom", header: map[string]string{"If-None-Match": `"v1"`}, code: http.StatusNotModified, etag: `"v1"`},
+		{name: "if-modified-since", path: "/custom", header: map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, code: http.StatusNotModified, etag: `"v1"`},
+		{name: "if-modified-since before modification", path: "/custom", header: map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, code: http.StatusOK, body: "custom", etag: `"v1"`},
+		{name: "if-unmodified-since before modification", path: "/custom", header: map[string]string{"If-Unmodified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, code: http.StatusPreconditionFailed, body: "Precondition Failed", etag: `"v1"`},
+		{name: "unsafe methods are not handled", method: http.MethodPost, path: "/", header: map[string]string{"If-None-Match": etag}, code: http.StatusOK, body: "hello"},
+		{name: "error responses are not handled", path: "/error", header: map[string]string{"If-None-Match": "*"}, code: http.StatusNotFound, body: "not found"},
+	}
+
+	for _, tc := range testCases {
+		var calls int
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Add([]string{http.MethodGet, http.MethodPost}, "/", func() string { return "hello" })
+			router.Get("/custom", func(ctx Context) Response {
+				if resp := CheckPreconditions(ctx, `"v1"`, modTime); resp != nil {
+					return resp
+				}
+
+				calls++
+				return ctx.HTML("custom")
+			})
+			router.Get("/error", func(ctx Context) Response {
+				return ctx.HTMLWithCode("not found", http.StatusNotFound)
+			})
+		}, NewRequestMiddleware().ETag(tc.weak))
+
+		method := tc.method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		req := httptest.NewRequest(method, tc.path, nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+
+		if got := w.Header().Get("ETag"); got != tc.etag {
+			t.Errorf("%s: expect etag %s, got %s", tc.name, tc.etag, got)
+		}
+
+		// handlers using CheckPreconditions skip their work for conditional requests
+		if tc.path == "/custom" && tc.code != http.StatusOK && calls != 0 {
+			t.Errorf("%s: expect handler short-circuited, got %d calls", tc.name, calls)
+		}
+	}
+}
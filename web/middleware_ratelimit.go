@@ -0,0 +1,121 @@
+package web
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTooManyRequests is the error when a request is rejected by rate limiter
+var ErrTooManyRequests = WrapPlainError(errors.New("too many requests"), http.StatusTooManyRequests)
+
+// RateLimitOptions is the options for RateLimit middleware
+type RateLimitOptions struct {
+	// Name is the prefix of keys, limiters share a store should have different names
+	Name string
+	// Algorithm is the rate limit algorithm, eg. TokenBucket(100, time.Minute) or SlidingWindow(100, time.Minute)
+	Algorithm RateLimitAlgorithm
+	// Store is the states storage, a MemoryRateLimitStore is used if it's nil
+	Store RateLimitStore
+	// Key return the identity of request to limit, requests are limited by client ip if it's nil
+	// return a empty string to skip rate limiting for the request
+	Key func(ctx Context) string
+	// PerRoute limits every route separately
+	PerRoute bool
+	// LimitExceeded create the response for rejected requests, ErrTooManyRequests is handled by exception pipeline if it's nil
+	LimitExceeded func(ctx Context, result RateLimitResult) Response
+}
+
+// RateLimit create a rate limit middleware, RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers are set to responses,
+// rejected requests get a 429 response with Retry-After header
+func (rm RequestMiddleware) RateLimit(opts RateLimitOptions) HandlerDecorator {
+	if opts.Algorithm == nil {
+		panic("rate limit algorithm is required")
+	}
+
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+
+	if opts.Key == nil {
+		opts.Key = RateLimitByIP
+	}
+
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			key := opts.Key(ctx)
+			if key == "" {
+				return handler(ctx)
+			}
+
+			if opts.PerRoute && ctx.Route() != nil {
+				key = strings.Join(ctx.Route().Methods(), ",") + " " + ctx.Route().Path() + "|" + key
+			}
+
+			if opts.Name != "" {
+				key = opts.Name + "|" + key
+			}
+
+			var result RateLimitResult
+			if err := opts.Store.Update(key, opts.Algorithm.TTL(), func(state *RateLimitState) {
+				result = opts.Algorithm.Take(state, time.Now())
+			}); err != nil {
+				// the store is unavailable, let the request go rather than rejecting all requests
+				return handler(ctx)
+			}
+
+			response := ctx.Response()
+			response.SetHeader("RateLimit-Limit", strconv.Itoa(result.Limit))
+			response.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			response.SetHeader("RateLimit-Reset", ceilSeconds(result.Reset))
+
+			if result.Allowed {
+				return handler(ctx)
+			}
+
+			response.SetHeader("Retry-After", ceilSeconds(result.RetryAfter))
+			if opts.LimitExceeded != nil {
+				return opts.LimitExceeded(ctx, result)
+			}
+
+			return exceptionResponse(ctx, ErrTooManyRequests)
+		}
+	}
+}
+
+// RateLimitByIP is a rate limit key function which identifies requests by client ip(remote address of connection)
+// when the server is behind proxies, use a custom key function which reads the trusted forwarded header
+func RateLimitByIP(ctx Context) string {
+	addr := ctx.Request().Raw().RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+// RateLimitByUser create a rate limit key function which identifies requests by user
+// user return the user identity of request, requests without user(empty string) are limited by client ip
+func RateLimitByUser(user func(ctx Context) string) func(ctx Context) string {
+	return func(ctx Context) string {
+		if id := user(ctx); id != "" {
+			return "user:" + id
+		}
+
+		return "ip:" + RateLimitByIP(ctx)
+	}
+}
+
+// RateLimitGlobal is a rate limit key function which shares one quota among all requests, use it with PerRoute
+// to limit the total requests of every route
+func RateLimitGlobal(ctx Context) string {
+	return "global"
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+func TestRateLimit(t *testing.T) {
+	testCases := []struct {
+		name      string
+		algorithm RateLimitAlgorithm
+		codes     []int
+		remaining []string
+	}{
+		{
+			name:      "token bucket",
+			algorithm: TokenBucket(2, time.Minute),
+			codes:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			remaining: []string{"1", "0", "0"},
+		},
+		{
+			name:      "sliding window",
+			algorithm: SlidingWindow(2, time.Minute),
+			codes:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			remaining: []string{"1", "0", "0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		logged := make(chan int, len(tc.codes))
+
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", func() string { return "ok" })
+		}, NewRequestMiddleware().RateLimit(RateLimitOptions{Algorithm: tc.algorithm}), NewRequestMiddleware().CustomAccessLog(func(cal CustomAccessLog) {
+			logged <- cal.ResponseCode
+		}))
+
+		for i, code := range tc.codes {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != code {
+				t.Errorf("%s: request %d: expect code %d, got %d", tc.name, i, code, w.Code)
+			}
+
+			if remaining := w.Header().Get("RateLimit-Remaining"); remaining != tc.remaining[i] {
+				t.Errorf("%s: request %d: expect remaining %s, got %s", tc.name, i, tc.remaining[i], remaining)
+			}
+
+			if code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Errorf("%s: request %d: expect Retry-After header", tc.name, i)
+			}
+
+			// rejected requests are visible to outer decorators
+			select {
+			case logged := <-logged:
+				if logged != code {
+					t.Errorf("%s: request %d: expect access log code %d, got %d", tc.name, i, code, logged)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("%s: request %d: expect access log", tc.name, i)
+			}
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	router := NewRouter(container.New(), DefaultConfig())
+	router.Group("", func(router *Router) {
+		router.Get("/a", func() string { return "a" })
+		router.Get("/b", func() string { return "b" })
+	}, NewRequestMiddleware().RateLimit(RateLimitOptions{
+		Algorithm: TokenBucket(1, time.Minute),
+		Key:       RateLimitByUser(func(ctx Context) string { return ctx.Header("X-User") }),
+		PerRoute:  true,
+	}))
+
+	testCases := []struct {
+		path string
+		user string
+		code int
+	}{
+		{path: "/a", user: "alice", code: http.StatusOK},
+		{path: "/a", user: "alice", code: http.StatusTooManyRequests},
+		{path: "/b", user: "alice", code: http.StatusOK},
+		{path: "/a", user: "bob", code: http.StatusOK},
+		// requests without user are limited by client ip
+		{path: "/a", code: http.StatusOK},
+		{path: "/a", code: http.StatusTooManyRequests},
+	}
+
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.user != "" {
+			req.Header.Set("X-User", tc.user)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("request %d (%s %s): expect code %d, got %d", i, tc.path, tc.user, tc.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHandlerTimeout is the error when handler doesn't finish in time
+// it wraps context.DeadlineExceeded, so it can be mapped to another status code(eg. 504) by Router.Errors
+var ErrHandlerTimeout = WrapPlainError(errors.WithMessage(context.DeadlineExceeded, "handler timeout"), http.StatusServiceUnavailable)
+
+// Timeout create a middleware which limits the handler running time, the timeout of route takes precedence if it's set
+// the handler gets a deadline context from Context.Context, when it's exceeded, ErrHandlerTimeout is handled by
+// exception pipeline and everything written by the abandoned handler later is discarded
+func (rm RequestMiddleware) Timeout(timeout time.Duration) HandlerDecorator {
+	return func(handler Handler) Handler {
+		return func(ctx Context) Response {
+			// route timeout is applied by router
+			if ctx.Route() != nil && ctx.Route().Timeout() > 0 {
+				return handler(ctx)
+			}
+
+			return withTimeout(handler, timeout)(ctx)
+		}
+	}
+}
+
+// withTimeout run handler in a new goroutine with a deadline context
+// the handler gets a child request and writes response through a guardedResponsor, which is closed when timeout
+func withTimeout(handler Handler, timeout time.Duration) Handler {
+	return func(ctx Context) Response {
+		wtx, ok := ctx.(*webContext)
+		if !ok {
+			return handler(ctx)
+		}
+
+		req, ok := wtx.request.(*httpRequest)
+		if !ok {
+			return handler(ctx)
+		}
+
+		parent := req.r.Context()
+		timeoutCtx, cancel := context.WithTimeout(parent, timeout)
+
+		var state responsorState
+		raw, restorable := wtx.responsor.(*simpleResponser)
+		if restorable {
+			state = raw.snapshot()
+		}
+
+		childReq := req.fork(timeoutCtx)
+		guard := newGuardedResponsor(wtx.responsor)
+		child := &webContext{
+			responsor: guard,
+			request:   childReq,
+			cc:        wtx.cc,
+			conf:      wtx.conf,
+			route:     wtx.route,
+			router:    wtx.router,
+		}
+
+		// decided is closed after the result is chosen, abandoned tells the goroutine to clean up the child request itself
+		decided := make(chan struct{})
+		abandoned := false
+
+		done := make(chan Response, 1)
+		panicked := make(chan recoveredPanic, 1)
+		go func() {
+			var late Response
+			defer func() {
+				if err := recover(); err != nil {
+					panicked <- recoveredPanic{value: err, stack: debug.Stack()}
+				}
+
+				<-decided
+				if abandoned {
+					// deferred responses are sent to the closed responsor, so they release resources and run OnSent callbacks
+					if deferred, ok := late.(DeferredResponse); ok {
+						_ = deferred.Send()
+					}
+
+					req.release(childReq)
+				}
+			}()
+
+			late = handler(child)
+			done <- late
+		}()
+
+		select {
+		case resp := <-done:
+			guard.detach()
+			req.join(childReq)
+			close(decided)
+
+			// streaming responses still use the deadline context while sending
+			if deferred, ok := resp.(DeferredResponse); ok {
+				deferred.OnSent(func(Response, error) { cancel() })
+			} else {
+				cancel()
+			}
+
+			return resp
+		case p := <-panicked:
+			guard.close()
+			abandoned = true
+			close(decided)
+			cancel()
+
+			panic(p)
+		case <-timeoutCtx.Done():
+			guard.close()
+			abandoned = true
+			close(decided)
+			cancel()
+
+			// discard everything the handler set to response before timeout
+			if restorable {
+				raw.restore(state)
+			}
+
+			if err := parent.Err(); err != nil {
+				// the client is gone before timeout
+				panic(WrapPlainError(err, http.StatusServiceUnavailable))
+			}
+
+			panic(ErrHandlerTimeout)
+		}
+	}
+}
+
+// guardedResponsor is a Responsor which discards all operations after closed
+// so that the abandoned handler goroutine can not corrupt the response
+type guardedResponsor struct {
+	lock     sync.Mutex
+	idle     *sync.Cond
+	response Responsor
+	closed   bool
+	// running is the count of operations in progress
+	running int
+	// detached is set when handler finished in time, operations are passed through directly
+	detached int32
+
+	// headers and rawHeaders are the header maps returned by Headers and Raw().Header() before detached
+	// they are copies of the response headers, changes to them are applied to the response when detached
+	copyLock       sync.Mutex
+	headers        http.Header
+	headersBase    http.Header
+	rawHeaders     http.Header
+	rawHeadersBase http.Header
+}
+
+func newGuardedResponsor(response Responsor) *guardedResponsor {
+	g := &guardedResponsor{response: response}
+	g.idle = sync.NewCond(&g.lock)
+
+	return g
+}
+
+// close reject all operations later, and wait for the ones in progress
+func (g *guardedResponsor) close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.closed = true
+	for g.running > 0 {
+		g.idle.Wait()
+	}
+}
+
+// detach apply the changes to header copies and pass operations through directly
+func (g *guardedResponsor) detach() {
+	g.copyLock.Lock()
+	if g.headers != nil {
+		applyHeaderChanges(g.response.Headers(), g.headersBase, g.headers)
+	}
+
+	if g.rawHeaders != nil {
+		applyHeaderChanges(g.response.Raw().Header(), g.rawHeadersBase, g.rawHeaders)
+	}
+	g.copyLock.Unlock()
+
+	atomic.StoreInt32(&g.detached, 1)
+}
+
+// headerCopy return the copy of header returned by get, it's created at the first call
+func (g *guardedResponsor) headerCopy(headers, base *http.Header, get func() http.Header) http.Header {
+	g.copyLock.Lock()
+	defer g.copyLock.Unlock()
+
+	if *headers == nil {
+		*base, *headers = make(http.Header), make(http.Header)
+		g.guard(func() {
+			*base = get().Clone()
+			*headers = base.Clone()
+		})
+	}
+
+	return *headers
+}
+
+// applyHeaderChanges apply the changes from base to changed to dst, headers not changed are kept as they are in dst
+func applyHeaderChanges(dst, base, changed http.Header) {
+	for k, v := range changed {
+		if !stringsEqual(base[k], v) {
+			dst[k] = append([]string(nil), v...)
+		}
+	}
+
+	for k := range base {
+		if _, ok := changed[k]; !ok {
+			delete(dst, k)
+		}
+	}
+}
+
+// guard run fn if the responsor is not closed, close waits until fn returns
+// the lock is not held while running fn, so fn can use the responsor again(eg. BeforeFlush callbacks)
+func (g *guardedResponsor) guard(fn func()) {
+	if atomic.LoadInt32(&g.detached) == 1 {
+		fn()
+		return
+	}
+
+	g.lock.Lock()
+	if g.closed {
+		g.lock.Unlock()
+		return
+	}
+	g.running++
+	g.lock.Unlock()
+
+	defer func() {
+		g.lock.Lock()
+		g.running--
+		g.idle.Broadcast()
+		g.lock.Unlock()
+	}()
+
+	fn()
+}
+
+func (g *guardedResponsor) Raw() http.ResponseWriter {
+	return &guardedWriter{guard: g}
+}
+
+func (g *guardedResponsor) ResponseWriter() http.ResponseWriter {
+	return g.Raw()
+}
+
+func (g *guardedResponsor) SetCode(code int) {
+	g.guard(func() { g.response.SetCode(code) })
+}
+
+func (g *guardedResponsor) GetCode() (code int) {
+	g.guard(func() { code = g.response.GetCode() })
+	return
+}
+
+func (g *guardedResponsor) SetContent(content []byte) {
+	g.guard(func() { g.response.SetContent(content) })
+}
+
+func (g *guardedResponsor) GetContent() (content []byte) {
+	g.guard(func() { content = g.response.GetContent() })
+	return
+}
+
+func (g *guardedResponsor) Header(key string, values ...string) {
+	g.guard(func() { g.response.Header(key, values...) })
+}
+
+func (g *guardedResponsor) SetHeader(key string, values ...string) {
+	g.guard(func() { g.response.SetHeader(key, values...) })
+}
+
+func (g *guardedResponsor) AddHeader(key string, values ...string) {
+	g.guard(func() { g.response.AddHeader(key, values...) })
+}
+
+func (g *guardedResponsor) DelHeader(key string) {
+	g.guard(func() { g.response.DelHeader(key) })
+}
+
+func (g *guardedResponsor) GetHeader(key string) (value string) {
+	g.guard(func() { value = g.response.GetHeader(key) })
+	return
+}
+
+// Headers return the headers, before the handler finished, it's a copy whose changes are applied when the handler finished in time
+func (g *guardedResponsor) Headers() http.Header {
+	if atomic.LoadInt32(&g.detached) == 1 {
+		return g.response.Headers()
+	}
+
+	return g.headerCopy(&g.headers, &g.headersBase, g.response.Headers)
+}
+
+func (g *guardedResponsor) Cookie(cookie *http.Cookie) {
+	g.guard(func() { g.response.Cookie(cookie) })
+}
+
+func (g *guardedResponsor) ClearCookie(name string, path string) {
+	g.guard(func() { g.response.ClearCookie(name, path) })
+}
+
+func (g *guardedResponsor) Cookies() (cookies []*http.Cookie) {
+	g.guard(func() { cookies = g.response.Cookies() })
+	return
+}
+
+func (g *guardedResponsor) Flush() {
+	g.guard(g.response.Flush)
+}
+
+func (g *guardedResponsor) FlushHeader() {
+	g.guard(g.response.FlushHeader)
+}
+
+func (g *guardedResponsor) BeforeFlush(fn func()) {
+	g.guard(func() { g.response.BeforeFlush(fn) })
+}
+
+func (g *guardedResponsor) Hijack() (conn net.Conn, rw *bufio.ReadWriter, err error) {
+	err = http.ErrHandlerTimeout
+	g.guard(func() { conn, rw, err = g.response.Hijack() })
+	return
+}
+
+// guardedWriter is the http.ResponseWriter of guardedResponsor
+type guardedWriter struct {
+	guard *guardedResponsor
+}
+
+// Header return the headers of underlying writer
+// before the handler finished, it's a copy whose changes are applied when the handler finished in time
+func (w *guardedWriter) Header() http.Header {
+	if atomic.LoadInt32(&w.guard.detached) == 1 {
+		return w.guard.response.Raw().Header()
+	}
+
+	return w.guard.headerCopy(&w.guard.rawHeaders, &w.guard.rawHeadersBase, func() http.Header {
+		return w.guard.response.Raw().Header()
+	})
+}
+
+func (w *guardedWriter) Write(p []byte) (n int, err error) {
+	err = http.ErrHandlerTimeout
+	w.guard.guard(func() { n, err = w.guard.response.Raw().Write(p) })
+	return
+}
+
+func (w *guardedWriter) WriteHeader(code int) {
+	w.guard.guard(func() { w.guard.response.Raw().WriteHeader(code) })
+}
+
+func (w *guardedWriter) Flush() {
+	w.guard.guard(func() {
+		if flusher, ok := w.guard.response.Raw().(http.Flusher); ok {
+			flusher.Flush()
+		}
+	})
+}
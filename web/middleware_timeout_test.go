@@ -0,0 +1,215 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler interface{}
+		code    int
+		body    string
+		header  map[string]string
+	}{
+		{
+			name: "finished in time",
+			handler: func(ctx Context) Response {
+				ctx.Response().SetHeader("X-Set", "1")
+				ctx.Response().Headers().Set("X-Headers", "2")
+				ctx.Response().Raw().Header().Set("X-Raw", "3")
+				ctx.Response().Headers().Del("X-Outer")
+				return ctx.HTML("ok")
+			},
+			code:   http.StatusOK,
+			body:   "ok",
+			header: map[string]string{"X-Set": "1", "X-Headers": "2", "X-Raw": "3", "X-Outer": ""},
+		},
+		{
+			name: "timeout",
+			handler: func(ctx Context) Response {
+				ctx.Response().SetHeader("X-Early", "1")
+				<-ctx.Context().Done()
+				return ctx.HTML("late")
+			},
+			code:   http.StatusServiceUnavailable,
+			body:   "handler timeout: context deadline exceeded",
+			header: map[string]string{"X-Early": "", "X-Outer": ""},
+		},
+		{
+			name: "writes after timeout are discarded",
+			handler: func(ctx Context) Response {
+				<-ctx.Context().Done()
+				time.Sleep(10 * time.Millisecond)
+
+				ctx.Response().SetHeader("X-Late", "1")
+				ctx.Response().Headers().Set("X-Late-Headers", "1")
+				ctx.Response().Cookie(NewCookie("late", "1", 0))
+				_, _ = ctx.Response().Raw().Write([]byte("late"))
+				return ctx.HTML("late")
+			},
+			code:   http.StatusServiceUnavailable,
+			body:   "handler timeout: context deadline exceeded",
+			header: map[string]string{"X-Late": "", "X-Late-Headers": "", "Set-Cookie": ""},
+		},
+		{
+			name: "error returned in time",
+			handler: func() error {
+				return WrapPlainError(errors.New("not found"), http.StatusNotFound)
+			},
+			code: http.StatusNotFound,
+			body: "not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", tc.handler).WithTimeout(20 * time.Millisecond)
+		}, func(handler Handler) Handler {
+			return func(ctx Context) Response {
+				ctx.Response().SetHeader("X-Outer", "outer")
+				return handler(ctx)
+			}
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		// wait for abandoned handlers, their writes must not reach the client
+		time.Sleep(30 * time.Millisecond)
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+
+		for k, v := range tc.header {
+			if w.Header().Get(k) != v {
+				t.Errorf("%s: expect header %s=%q, got %q", tc.name, k, v, w.Header().Get(k))
+			}
+		}
+	}
+}
+
+func TestRouteTimeoutPanic(t *testing.T) {
+	var reported int32
+	var stack []byte
+
+	router := NewRouter(container.New(), DefaultConfig())
+	router.WithPanicReporter(PanicReporterFunc(func(ctx Context, err error, s []byte) {
+		atomic.AddInt32(&reported, 1)
+		stack = s
+	}))
+	router.Get("/", func() string {
+		panic("boom")
+	}).WithTimeout(time.Second)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expect code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if atomic.LoadInt32(&reported) != 1 {
+		t.Fatalf("expect panic reported once, got %d", reported)
+	}
+
+	// the stack is captured in the handler goroutine
+	if !bytes.Contains(stack, []byte("middleware_timeout_test.go")) {
+		t.Errorf("expect stack of the handler goroutine, got %s", stack)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	router := NewRouter(container.New(), DefaultConfig())
+	router.Group("", func(router *Router) {
+		router.Get("/slow", func(ctx Context) string {
+			<-ctx.Context().Done()
+			return "slow"
+		})
+		router.Get("/fast", func(ctx Context) string {
+			if _, ok := ctx.Context().Deadline(); !ok {
+				return "no deadline"
+			}
+
+			return "fast"
+		})
+		// route timeout takes precedence over the middleware
+		router.Get("/route", func(ctx Context) string {
+			time.Sleep(30 * time.Millisecond)
+			return "route"
+		}).WithTimeout(time.Second)
+	}, NewRequestMiddleware().Timeout(20*time.Millisecond))
+
+	testCases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/slow", code: http.StatusServiceUnavailable, body: "handler timeout: context deadline exceeded"},
+		{path: "/fast", code: http.StatusOK, body: "fast"},
+		{path: "/route", code: http.StatusOK, body: "route"},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if w.Code != tc.code || w.Body.String() != tc.body {
+			t.Errorf("%s: expect %d %q, got %d %q", tc.path, tc.code, tc.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouteTimeoutLateDeferredResponse(t *testing.T) {
+	sent := make(chan error, 1)
+
+	router := NewRouter(container.New(), DefaultConfig())
+	router.Get("/", func(ctx Context) Response {
+		<-ctx.Context().Done()
+
+		resp := ctx.Stream("text/plain", func(w io.Writer) error {
+			_, err := w.Write([]byte("late"))
+			return err
+		})
+		resp.OnSent(func(_ Response, err error) { sent <- err })
+
+		return resp
+	}).WithTimeout(20 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	// the late stream is sent to the closed responsor, so its callbacks still run
+	select {
+	case err := <-sent:
+		if err == nil {
+			t.Errorf("expect the late stream failed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect OnSent callbacks of the late stream called")
+	}
+
+	if strings.Contains(w.Body.String(), "late") {
+		t.Errorf("expect late stream discarded, got %q", w.Body.String())
+	}
+}
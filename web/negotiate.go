@@ -0,0 +1,150 @@
+package web
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a media range in Accept header
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// specificity return how specific the media range is, exact type is more specific than wildcard
+func (r acceptRange) specificity() int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// match return whether the media range matches the media type
+func (r acceptRange) match(typ, subtype string) bool {
+	return (r.typ == "*" || r.typ == typ) && (r.subtype == "*" || r.subtype == subtype)
+}
+
+// parseAccept parse Accept header to media ranges, invalid ranges are ignored
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, item := range strings.Split(accept, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+
+		segs := strings.SplitN(mediaType, "/", 2)
+		if len(segs) != 2 {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{typ: segs[0], subtype: segs[1], q: q})
+	}
+
+	return ranges
+}
+
+// NegotiateMediaType choose the best media type from offers according to Accept header
+// offers earlier in the list are preferred when they have same quality, an empty Accept header accepts the first offer
+func NegotiateMediaType(accept string, offers []string) (string, bool) {
+	acceptable := acceptableMediaTypes(accept, offers)
+	if len(acceptable) == 0 {
+		return "", false
+	}
+
+	return acceptable[0], true
+}
+
+// acceptableMediaTypes return offers acceptable by Accept header, ordered from the most preferred one
+// offers earlier in the list are preferred when they have same quality, an empty Accept header accepts all offers
+func acceptableMediaTypes(accept string, offers []string) []string {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offers
+	}
+
+	acceptable := make([]string, 0)
+	qualities := make(map[string]float64)
+	for _, offer := range offers {
+		segs := strings.SplitN(parseMediaType(offer), "/", 2)
+		if len(segs) != 2 {
+			continue
+		}
+
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if r.match(segs[0], segs[1]) && r.specificity() > specificity {
+				q, specificity = r.q, r.specificity()
+			}
+		}
+
+		if q > 0 {
+			acceptable = append(acceptable, offer)
+			qualities[offer] = q
+		}
+	}
+
+	sort.SliceStable(acceptable, func(i, j int) bool {
+		return qualities[acceptable[i]] > qualities[acceptable[j]]
+	})
+
+	return acceptable
+}
+
+// NegotiateEncoding choose the best content coding from offers according to Accept-Encoding header
+// offers earlier in the list are preferred when they have same quality, no coding is chosen for an empty header
+func NegotiateEncoding(acceptEncoding string, offers []string) (string, bool) {
+	qualities := make(map[string]float64)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		segs := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(segs[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range segs[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil && v >= 0 && v <= 1 {
+					q = v
+				}
+			}
+		}
+
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, ok := qualities[strings.ToLower(offer)]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best, best != ""
+}
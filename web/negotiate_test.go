@@ -0,0 +1,145 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	offers := []string{"application/json", "application/yaml", "text/html"}
+
+	testCases := []struct {
+		accept string
+		expect string
+		ok     bool
+	}{
+		{accept: "", expect: "application/json", ok: true},
+		{accept: "*/*", expect: "application/json", ok: true},
+		{accept: "text/html", expect: "text/html", ok: true},
+		{accept: "text/*", expect: "text/html", ok: true},
+		{accept: "application/yaml, application/json", expect: "application/json", ok: true},
+		{accept: "application/json;q=0.5, application/yaml", expect: "application/yaml", ok: true},
+		{accept: "*/*;q=0.1, text/html;q=0.5", expect: "text/html", ok: true},
+		{accept: "application/*, application/json;q=0", expect: "application/yaml", ok: true},
+		{accept: "invalid, text/html;q=2, application/yaml", expect: "application/yaml", ok: true},
+		{accept: "image/png", ok: false},
+		{accept: "*/*;q=0", ok: false},
+	}
+
+	for _, tc := range testCases {
+		mediaType, ok := NegotiateMediaType(tc.accept, offers)
+		if mediaType != tc.expect || ok != tc.ok {
+			t.Errorf("%q: expect %q %v, got %q %v", tc.accept, tc.expect, tc.ok, mediaType, ok)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offers := []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+
+	testCases := []struct {
+		accept string
+		expect string
+		ok     bool
+	}{
+		{accept: "", ok: false},
+		{accept: "gzip", expect: EncodingGzip, ok: true},
+		{accept: "gzip, br", expect: EncodingBrotli, ok: true},
+		{accept: "GZIP;q=1, br;q=0.5", expect: EncodingGzip, ok: true},
+		{accept: "*", expect: EncodingBrotli, ok: true},
+		{accept: "*;q=0.5, br;q=0, gzip;q=0", expect: EncodingDeflate, ok: true},
+		{accept: "identity", ok: false},
+		{accept: "br;q=0", ok: false},
+	}
+
+	for _, tc := range testCases {
+		encoding, ok := NegotiateEncoding(tc.accept, offers)
+		if encoding != tc.expect || ok != tc.ok {
+			t.Errorf("%q: expect %q %v, got %q %v", tc.accept, tc.expect, tc.ok, encoding, ok)
+		}
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "default",
+			code:        http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"router"}`,
+		},
+		{
+			name:        "yaml",
+			accept:      "application/yaml",
+			code:        http.StatusOK,
+			contentType: "application/yaml; charset=utf-8",
+			body:        "name: router\n",
+		},
+		{
+			name:        "alias",
+			accept:      "text/yaml",
+			code:        http.StatusOK,
+			contentType: "application/yaml; charset=utf-8",
+			body:        "name: router\n",
+		},
+		{
+			name:        "quality",
+			accept:      "application/json;q=0.5, application/yaml",
+			code:        http.StatusOK,
+			contentType: "application/yaml; charset=utf-8",
+			body:        "name: router\n",
+		},
+		{
+			name:        "the next acceptable codec is used if a codec can not encode the value",
+			accept:      "application/xml, application/json;q=0.9",
+			code:        http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"router"}`,
+		},
+		{
+			name:        "not acceptable",
+			accept:      "image/png",
+			code:        http.StatusNotAcceptable,
+			contentType: "text/plain; charset=utf-8",
+			body:        http.StatusText(http.StatusNotAcceptable),
+		},
+	}
+
+	router := NewRouter(container.New(), DefaultConfig()).WithContentNegotiation()
+	router.Get("/", func() M { return M{"name": "router"} })
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != tc.contentType {
+			t.Errorf("%s: expect content type %q, got %q", tc.name, tc.contentType, contentType)
+		}
+
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("%s: expect Vary: Accept, got %q", tc.name, vary)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Problem is a problem details object defined in RFC 7807
+// it implements Error and JSONAble, so it can be returned or panic from handlers
+type Problem struct {
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+
+	cause error
+}
+
+// NewProblem create a Problem with status and detail, the title is the status text
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     detail,
+		Extensions: make(map[string]interface{}),
+	}
+}
+
+// WrapProblem wrap a error to Problem, the error message is used as detail
+func WrapProblem(err error, status int) *Problem {
+	problem := NewProblem(status, err.Error())
+	problem.cause = err
+
+	return problem
+}
+
+// ProblemFromError convert a error to Problem
+// Problem in error chain is returned directly, the status comes from Error in chain or 500,
+// and ValidationErrors in chain are mapped to "errors" extension
+func ProblemFromError(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		if problem.Status == 0 {
+			p := *problem
+			p.Status = http.StatusInternalServerError
+			return &p
+		}
+
+		return problem
+	}
+
+	status := http.StatusInternalServerError
+	var httpErr Error
+	if errors.As(err, &httpErr) {
+		status = httpErr.StatusCode()
+	}
+
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		if status == http.StatusInternalServerError {
+			status = http.StatusUnprocessableEntity
+		}
+
+		return WrapProblem(err, status).WithExtension("errors", validationErrs)
+	}
+
+	return WrapProblem(err, status)
+}
+
+// WithType set the problem type uri and return itself
+func (p *Problem) WithType(typ string) *Problem {
+	p.Type = typ
+	return p
+}
+
+// WithTitle set the problem title and return itself
+func (p *Problem) WithTitle(title string) *Problem {
+	p.Title = title
+	return p
+}
+
+// WithInstance set the problem instance uri and return itself
+func (p *Problem) WithInstance(instance string) *Problem {
+	p.Instance = instance
+	return p
+}
+
+// WithExtension add a extension member and return itself
+func (p *Problem) WithExtension(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+
+	return p.Title
+}
+
+// StatusCode return the problem status, 500 if it's not set
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return p.Status
+}
+
+// Unwrap return the wrapped error
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+func (p *Problem) ToJSON() interface{} {
+	return p
+}
+
+// MarshalJSON encode the problem with extension members at top level
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	res := make(map[string]interface{})
+	for k, v := range p.Extensions {
+		res[k] = v
+	}
+
+	type problem Problem
+	data, err := json.Marshal((*problem)(p))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(res)
+}
+
+// ValidationErrors is a collection of error messages keyed by field name
+// validators can return it so that the errors are exposed as "errors" extension of Problem
+type ValidationErrors map[string][]string
+
+// Add append a error message for field
+func (v ValidationErrors) Add(field string, message string) {
+	v[field] = append(v[field], message)
+}
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field+": "+strings.Join(v[field], ", "))
+	}
+
+	return strings.Join(messages, "; ")
+}
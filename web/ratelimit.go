@@ -0,0 +1,199 @@
+package web
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the result of taking a request from rate limiter
+type RateLimitResult struct {
+	// Allowed is whether the request is allowed
+	Allowed bool
+	// Limit is the max requests in a period
+	Limit int
+	// Remaining is the requests left in current period
+	Remaining int
+	// Reset is the duration until the quota is fully restored
+	Reset time.Duration
+	// RetryAfter is the duration until the next request is allowed, only set for rejected requests
+	RetryAfter time.Duration
+}
+
+// RateLimitState is the state of a rate limit key, it's used by algorithms and saved in RateLimitStore
+type RateLimitState struct {
+	// Tokens and Last are used by token bucket
+	Tokens float64
+	Last   time.Time
+	// WindowStart, Count and PrevCount are used by sliding window
+	WindowStart time.Time
+	Count       int
+	PrevCount   int
+}
+
+// RateLimitAlgorithm is a rate limit algorithm which updates state for every request
+type RateLimitAlgorithm interface {
+	// Take take a request at now and update the state
+	Take(state *RateLimitState, now time.Time) RateLimitResult
+	// TTL is how long the state should be kept after last update
+	TTL() time.Duration
+}
+
+// RateLimitStore is a interface for saving rate limit states
+type RateLimitStore interface {
+	// Update atomically update the state of key with fn, a new state is created if it doesn't exist or expired
+	Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// tokenBucket is the token bucket algorithm, tokens are refilled continuously at limit/period
+type tokenBucket struct {
+	limit  int
+	period time.Duration
+}
+
+// TokenBucket create a token bucket algorithm, which allows bursts up to limit and refills limit tokens every period
+func TokenBucket(limit int, period time.Duration) RateLimitAlgorithm {
+	return tokenBucket{limit: limit, period: period}
+}
+
+func (tb tokenBucket) Take(state *RateLimitState, now time.Time) RateLimitResult {
+	rate := float64(tb.limit) / tb.period.Seconds()
+	if state.Last.IsZero() {
+		state.Tokens = float64(tb.limit)
+	} else if elapsed := now.Sub(state.Last).Seconds(); elapsed > 0 {
+		state.Tokens = math.Min(float64(tb.limit), state.Tokens+elapsed*rate)
+	}
+	state.Last = now
+
+	result := RateLimitResult{Limit: tb.limit}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - state.Tokens) / rate)
+	}
+
+	result.Remaining = int(state.Tokens)
+	result.Reset = secondsDuration((float64(tb.limit) - state.Tokens) / rate)
+
+	return result
+}
+
+func (tb tokenBucket) TTL() time.Duration {
+	return tb.period
+}
+
+// slidingWindow is the sliding window counter algorithm
+// the count of previous window is weighted by its overlap with the sliding window
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow create a sliding window algorithm, which allows limit requests in any window
+func SlidingWindow(limit int, window time.Duration) RateLimitAlgorithm {
+	return slidingWindow{limit: limit, window: window}
+}
+
+func (sw slidingWindow) Take(state *RateLimitState, now time.Time) RateLimitResult {
+	start := now.Truncate(sw.window)
+	if !state.WindowStart.Equal(start) {
+		if state.WindowStart.Add(sw.window).Equal(start) {
+			state.PrevCount = state.Count
+		} else {
+			state.PrevCount = 0
+		}
+
+		state.WindowStart = start
+		state.Count = 0
+	}
+
+	weight := 1 - float64(now.Sub(start))/float64(sw.window)
+	used := float64(state.PrevCount)*weight + float64(state.Count)
+
+	result := RateLimitResult{Limit: sw.limit, Reset: start.Add(sw.window).Sub(now)}
+	if used+1 <= float64(sw.limit) {
+		state.Count++
+		used++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = sw.retryAfter(state, now, start)
+	}
+
+	result.Remaining = int(math.Max(0, float64(sw.limit)-used))
+	return result
+}
+
+// retryAfter estimate when the weighted count drops enough for one more request
+func (sw slidingWindow) retryAfter(state *RateLimitState, now time.Time, start time.Time) time.Duration {
+	if state.PrevCount > 0 && state.Count+1 <= sw.limit {
+		// prev * (1 - t/window) + count + 1 <= limit
+		t := (1 - float64(sw.limit-state.Count-1)/float64(state.PrevCount)) * float64(sw.window)
+		if wait := start.Add(time.Duration(t)).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+
+	return start.Add(sw.window).Sub(now)
+}
+
+func (sw slidingWindow) TTL() time.Duration {
+	return 2 * sw.window
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// MemoryRateLimitStore is a in-memory RateLimitStore
+type MemoryRateLimitStore struct {
+	lock      sync.Mutex
+	states    map[string]*memoryRateLimitState
+	lastSweep time.Time
+}
+
+type memoryRateLimitState struct {
+	state    RateLimitState
+	expireAt time.Time
+}
+
+// NewMemoryRateLimitStore create a MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		states:    make(map[string]*memoryRateLimitState),
+		lastSweep: time.Now(),
+	}
+}
+
+func (store *MemoryRateLimitStore) Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	now := time.Now()
+	store.sweep(now, ttl)
+
+	item, ok := store.states[key]
+	if !ok || now.After(item.expireAt) {
+		item = &memoryRateLimitState{}
+		store.states[key] = item
+	}
+
+	fn(&item.state)
+	item.expireAt = now.Add(ttl)
+
+	return nil
+}
+
+// sweep remove expired states periodically, so that the map doesn't grow forever
+func (store *MemoryRateLimitStore) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(store.lastSweep) < interval {
+		return
+	}
+
+	store.lastSweep = now
+	for key, item := range store.states {
+		if now.After(item.expireAt) {
+			delete(store.states, key)
+		}
+	}
+}
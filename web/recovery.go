@@ -0,0 +1,151 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicReporter is a interface for reporting panics to external services(eg. sentry)
+type PanicReporter interface {
+	ReportPanic(ctx Context, err error, stack []byte)
+}
+
+// PanicReporterFunc is a function adapter for PanicReporter
+type PanicReporterFunc func(ctx Context, err error, stack []byte)
+
+func (fn PanicReporterFunc) ReportPanic(ctx Context, err error, stack []byte) {
+	fn(ctx, err, stack)
+}
+
+// WithPanicReporter add a reporter which will be called with the stack trace when handler panics
+func (router *Router) WithPanicReporter(reporter PanicReporter) *Router {
+	router.panicReporters = append(router.panicReporters, reporter)
+	return router
+}
+
+// WithDebugPage render a debug page with error and stack trace when handler panics
+// it exposes source details, so only enable it in development mode
+func (router *Router) WithDebugPage() *Router {
+	router.debugPage = true
+	return router
+}
+
+// panicError convert a recovered value to error
+func panicError(val interface{}) error {
+	if err, ok := val.(error); ok {
+		return err
+	}
+
+	return fmt.Errorf("%v", val)
+}
+
+// isExpectedPanic return whether the panic is a error panicked to framework intentionally (eg. Request.Validate),
+// they are handled by exception handler without reporting
+func isExpectedPanic(val interface{}) bool {
+	_, ok := val.(Error)
+	return ok
+}
+
+// recoveredPanic is a panic recovered in another goroutine, it's panicked again with the original stack trace
+type recoveredPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// handlePanic create a response for the recovered value, unexpected panics are logged and reported with stack trace
+// it must be called in the deferred function directly, so that the stack trace contains the panic location
+func (router *Router) handlePanic(ctx Context, val interface{}) Response {
+	var stack []byte
+	if p, ok := val.(recoveredPanic); ok {
+		val, stack = p.value, p.stack
+	}
+
+	if isExpectedPanic(val) {
+		return router.handleException(ctx, val.(error))
+	}
+
+	if stack == nil {
+		stack = debug.Stack()
+	}
+
+	err := router.reportPanic(ctx, val, stack)
+	if router.debugPage {
+		return &debugPageResponse{response: ctx.Response(), request: ctx.Request(), err: err, stack: stack}
+	}
+
+	return router.handleException(ctx, err)
+}
+
+// reportPanic send the panic to logger and reporters
+func (router *Router) reportPanic(ctx Context, val interface{}, stack []byte) error {
+	err := panicError(val)
+	if router.logger != nil {
+		router.logger.Errorf("panic recovered: %v\n%s", err, stack)
+	}
+
+	for _, reporter := range router.panicReporters {
+		func() {
+			defer func() {
+				if e := recover(); e != nil && router.logger != nil {
+					router.logger.Errorf("panic reporter failed: %v", e)
+				}
+			}()
+
+			reporter.ReportPanic(ctx, err, stack)
+		}()
+	}
+
+	return err
+}
+
+var debugPageTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Error }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+h1 { color: #c0392b; font-size: 1.4em; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>{{ .Error }}</h1>
+<p>{{ .Method }} {{ .URL }}</p>
+<pre>{{ .Stack }}</pre>
+</body>
+</html>`))
+
+// debugPageResponse is a response shows the panic error and stack trace
+type debugPageResponse struct {
+	response Responsor
+	request  Request
+	err      error
+	stack    []byte
+}
+
+func (resp *debugPageResponse) Code() int {
+	return http.StatusInternalServerError
+}
+
+func (resp *debugPageResponse) Send() error {
+	var buf bytes.Buffer
+	if err := debugPageTemplate.Execute(&buf, map[string]interface{}{
+		"Error":  resp.err.Error(),
+		"Method": resp.request.Method(),
+		"URL":    resp.request.Raw().URL.String(),
+		"Stack":  string(resp.stack),
+	}); err != nil {
+		return err
+	}
+
+	resp.response.SetCode(http.StatusInternalServerError)
+	resp.response.Header("Content-Type", "text/html; charset=utf-8")
+	resp.response.SetContent(buf.Bytes())
+
+	resp.response.Flush()
+	return nil
+}
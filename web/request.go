@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,8 +28,20 @@ type httpRequest struct {
 	cc         container.Container
 	conf       *Config
 	stores     map[string]interface{}
+	bodyErr    error
 	bodyLoader sync.Once
 	pathVars   map[string]string
+	queries    url.Values
+	tempFiles  []*UploadedFile
+	// closers is the resources bound to the request, they are closed when the request finished
+	closers []io.Closer
+
+	// forks is the running child requests created by fork, cleanup waits for them
+	forks sync.WaitGroup
+	// running is the count of child requests not joined or released
+	running int32
+	// forms is the multipart forms parsed by child requests
+	forms []*multipart.Form
 }
 
 // NewRequest create new Request
@@ -73,6 +86,10 @@ func (req *httpRequest) Decode(v interface{}) error {
 	return req.cc.ResolveWithError(func(decoder Decoder) error {
 		if req.ContentType() == "multipart/form-data" {
 			if err := req.r.ParseMultipartForm(req.conf.MultipartFormMaxMemory); err != nil {
+				if errors.Is(err, ErrRequestBodyTooLarge) {
+					return ErrRequestBodyTooLarge
+				}
+
 				return errors.Wrap(err, "parse multipart form failed")
 			}
 
@@ -84,6 +101,10 @@ func (req *httpRequest) Decode(v interface{}) error {
 		}
 
 		if err := req.r.ParseForm(); err != nil {
+			if errors.Is(err, ErrRequestBodyTooLarge) {
+				return ErrRequestBodyTooLarge
+			}
+
 			return errors.Wrap(err, "parse form failed")
 		}
 
@@ -98,13 +119,41 @@ func (req *httpRequest) Decode(v interface{}) error {
 // Unmarshal unmarshal request body as json object
 // result must be reference to a variable
 func (req *httpRequest) Unmarshal(v interface{}) error {
-	return json.Unmarshal(req.Body(), v)
+	body, err := req.loadBody()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
 }
 
 // UnmarshalYAML unmarshal request body as yaml object
 // result must be reference to a variable
 func (req *httpRequest) UnmarshalYAML(v interface{}) error {
-	return yaml.Unmarshal(req.Body(), v)
+	body, err := req.loadBody()
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(body, v)
+}
+
+// UnmarshalAuto unmarshal request body with the codec chosen by Content-Type
+// result must be reference to a variable
+func (req *httpRequest) UnmarshalAuto(v interface{}) error {
+	return req.cc.ResolveWithError(func(codecs *CodecRegistry) error {
+		codec, ok := codecs.Get(req.ContentType())
+		if !ok {
+			return ErrUnsupportedMediaType
+		}
+
+		body, err := req.loadBody()
+		if err != nil {
+			return err
+		}
+
+		return codec.Unmarshal(body, v)
+	})
 }
 
 // Set 设置一个变量，存储到当前请求
@@ -137,16 +186,116 @@ func (req *httpRequest) PathVars() map[string]string {
 	return req.pathVars
 }
 
-// Input return form parameter from request
+// Input return form parameter from request, the sources are looked up in order of Config.InputPrecedence
 func (req *httpRequest) Input(key string) string {
-	if req.IsJSON() {
-		val := req.JSONGet(key)
+	for _, source := range req.inputPrecedence() {
+		var val string
+		switch source {
+		case InputSourceJSON:
+			if req.IsJSON() {
+				val = req.JSONGet(key)
+			}
+		case InputSourceForm:
+			val = req.PostForm(key)
+		case InputSourceQuery:
+			val = req.Query(key)
+		}
+
 		if val != "" {
 			return val
 		}
 	}
 
-	return req.r.FormValue(key)
+	return ""
+}
+
+// Query return a parameter from url query string only
+func (req *httpRequest) Query(key string) string {
+	return req.query().Get(key)
+}
+
+// QueryWithDefault return a parameter from url query string with a default value
+func (req *httpRequest) QueryWithDefault(key string, defaultVal string) string {
+	val := req.Query(key)
+	if val == "" {
+		return defaultVal
+	}
+
+	return val
+}
+
+// QueryArray return all values for a parameter from url query string
+func (req *httpRequest) QueryArray(key string) []string {
+	return formArray(req.query(), key)
+}
+
+// IntQuery return a integer parameter from url query string
+func (req *httpRequest) IntQuery(key string, defaultVal int) int {
+	return req.ToInt(req.Query(key), defaultVal)
+}
+
+// Int64Query return a int64 parameter from url query string
+func (req *httpRequest) Int64Query(key string, defaultVal int64) int64 {
+	return req.ToInt64(req.Query(key), defaultVal)
+}
+
+// Float32Query return a float32 parameter from url query string
+func (req *httpRequest) Float32Query(key string, defaultVal float32) float32 {
+	return req.ToFloat32(req.Query(key), defaultVal)
+}
+
+// Float64Query return a float64 parameter from url query string
+func (req *httpRequest) Float64Query(key string, defaultVal float64) float64 {
+	return req.ToFloat64(req.Query(key), defaultVal)
+}
+
+// PostForm return a parameter from urlencoded or multipart form body only, query string is ignored
+func (req *httpRequest) PostForm(key string) string {
+	return req.postForm().Get(key)
+}
+
+// PostFormWithDefault return a parameter from form body with a default value
+func (req *httpRequest) PostFormWithDefault(key string, defaultVal string) string {
+	val := req.PostForm(key)
+	if val == "" {
+		return defaultVal
+	}
+
+	return val
+}
+
+// PostFormArray return all values for a parameter from form body
+func (req *httpRequest) PostFormArray(key string) []string {
+	return formArray(req.postForm(), key)
+}
+
+// IntPostForm return a integer parameter from form body
+func (req *httpRequest) IntPostForm(key string, defaultVal int) int {
+	return req.ToInt(req.PostForm(key), defaultVal)
+}
+
+// Int64PostForm return a int64 parameter from form body
+func (req *httpRequest) Int64PostForm(key string, defaultVal int64) int64 {
+	return req.ToInt64(req.PostForm(key), defaultVal)
+}
+
+// Float32PostForm return a float32 parameter from form body
+func (req *httpRequest) Float32PostForm(key string, defaultVal float32) float32 {
+	return req.ToFloat32(req.PostForm(key), defaultVal)
+}
+
+// Float64PostForm return a float64 parameter from form body
+func (req *httpRequest) Float64PostForm(key string, defaultVal float64) float64 {
+	return req.ToFloat64(req.PostForm(key), defaultVal)
+}
+
+// inputPrecedence return the input sources in order of precedence
+func (req *httpRequest) inputPrecedence() []InputSource {
+	if req.conf == nil || len(req.conf.InputPrecedence) == 0 {
+		return defaultInputPrecedence
+	}
+
+	return req.conf.InputPrecedence
 }
 
 func (req *httpRequest) JSONGet(keys ...string) string {
@@ -155,22 +304,23 @@ func (req *httpRequest) JSONGet(keys ...string) string {
 		return ""
 	}
 
+	return jsonValueToString(value, dataType)
+}
+
+// jsonValueToString convert a json value to string
+// numbers are returned as the literal text to keep the precision, objects and arrays are returned as json
+func jsonValueToString(value []byte, dataType jsonparser.ValueType) string {
 	switch dataType {
 	case jsonparser.String:
 		if res, err := jsonparser.ParseString(value); err == nil {
 			return res
 		}
 	case jsonparser.Number:
-		if res, err := jsonparser.ParseFloat(value); err == nil {
-			return strconv.FormatFloat(res, 'f', -1, 32)
-		}
-		if res, err := jsonparser.ParseInt(value); err == nil {
-			return fmt.Sprintf("%d", res)
-		}
+		return string(value)
 	case jsonparser.Object:
 		fallthrough
 	case jsonparser.Array:
-		return fmt.Sprintf("%x", value)
+		return string(value)
 	case jsonparser.Boolean:
 		if res, err := jsonparser.ParseBoolean(value); err == nil {
 			if res {
@@ -256,33 +406,246 @@ func (req *httpRequest) Float64Input(key string, defaultVal float64) float64 {
 	return req.ToFloat64(req.Input(key), defaultVal)
 }
 
-// File Retrieving Uploaded Files
-func (req *httpRequest) File(key string) (*UploadedFile, error) {
-	file, header, err := req.r.FormFile(key)
-	if err != nil {
-		return nil, err
+// InputArray return all values for a form parameter from request
+// it supports repeated keys(ids=1&ids=2), bracket notation(ids[]=1&ids[0]=2) and json arrays,
+// the sources are looked up in order of Config.InputPrecedence
+func (req *httpRequest) InputArray(key string) []string {
+	for _, source := range req.inputPrecedence() {
+		var values []string
+		switch source {
+		case InputSourceJSON:
+			if req.IsJSON() {
+				values, _ = req.jsonArray(key)
+			}
+		case InputSourceForm:
+			values = req.PostFormArray(key)
+		case InputSourceQuery:
+			values = req.QueryArray(key)
+		}
+
+		if len(values) > 0 {
+			return values
+		}
 	}
 
-	defer func() {
-		_ = file.Close()
-	}()
+	return make([]string, 0)
+}
+
+// InputMap return a map form parameter from request
+// it supports bracket notation(user[name]=abc&user[age]=12) and json objects,
+// the sources are looked up in order of Config.InputPrecedence
+func (req *httpRequest) InputMap(key string) map[string]string {
+	for _, source := range req.inputPrecedence() {
+		var values map[string]string
+		switch source {
+		case InputSourceJSON:
+			if req.IsJSON() {
+				values, _ = req.jsonMap(key)
+			}
+		case InputSourceForm:
+			values = formMap(req.postForm(), key)
+		case InputSourceQuery:
+			values = formMap(req.query(), key)
+		}
+
+		if len(values) > 0 {
+			return values
+		}
+	}
+
+	return make(map[string]string)
+}
+
+// IntArray return a integer array form parameter, invalid values will be ignored
+func (req *httpRequest) IntArray(key string) []int {
+	values := req.InputArray(key)
+	res := make([]int, 0, len(values))
+	for _, v := range values {
+		if i, err := strconv.Atoi(v); err == nil {
+			res = append(res, i)
+		}
+	}
 
-	tempFile, err := ioutil.TempFile(req.conf.TempDir, req.conf.TempFilePattern)
+	return res
+}
+
+// Int64Array return a int64 array form parameter, invalid values will be ignored
+func (req *httpRequest) Int64Array(key string) []int64 {
+	values := req.InputArray(key)
+	res := make([]int64, 0, len(values))
+	for _, v := range values {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// Float32Array return a float32 array form parameter, invalid values will be ignored
+func (req *httpRequest) Float32Array(key string) []float32 {
+	values := req.InputArray(key)
+	res := make([]float32, 0, len(values))
+	for _, v := range values {
+		if f, err := strconv.ParseFloat(v, 32); err == nil {
+			res = append(res, float32(f))
+		}
+	}
+
+	return res
+}
+
+// Float64Array return a float64 array form parameter, invalid values will be ignored
+func (req *httpRequest) Float64Array(key string) []float64 {
+	values := req.InputArray(key)
+	res := make([]float64, 0, len(values))
+	for _, v := range values {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
+
+// jsonArray return all values of a json array in request body
+func (req *httpRequest) jsonArray(key string) ([]string, bool) {
+	value, dataType, _, err := jsonparser.Get(req.Body(), key)
 	if err != nil {
-		return nil, fmt.Errorf("can not create temporary file %s", err.Error())
+		return nil, false
+	}
+
+	switch dataType {
+	case jsonparser.Array:
+		res := make([]string, 0)
+		_, _ = jsonparser.ArrayEach(value, func(val []byte, typ jsonparser.ValueType, _ int, _ error) {
+			res = append(res, jsonValueToString(val, typ))
+		})
+		return res, true
+	case jsonparser.NotExist, jsonparser.Null, jsonparser.Unknown:
+		return nil, false
+	default:
+		return []string{jsonValueToString(value, dataType)}, true
+	}
+}
+
+// jsonMap return all key-values of a json object in request body
+func (req *httpRequest) jsonMap(key string) (map[string]string, bool) {
+	value, dataType, _, err := jsonparser.Get(req.Body(), key)
+	if err != nil || dataType != jsonparser.Object {
+		return nil, false
+	}
+
+	res := make(map[string]string)
+	_ = jsonparser.ObjectEach(value, func(k []byte, val []byte, typ jsonparser.ValueType, _ int) error {
+		res[string(k)] = jsonValueToString(val, typ)
+		return nil
+	})
+
+	return res, true
+}
+
+// postForm parse the request body as form and return all form values, query parameters are excluded
+// if the request body exceeds the max body size, ErrRequestBodyTooLarge will be panic to framework
+func (req *httpRequest) postForm() url.Values {
+	var err error
+	if req.ContentType() == "multipart/form-data" {
+		if req.r.MultipartForm == nil {
+			err = req.r.ParseMultipartForm(req.conf.MultipartFormMaxMemory)
+		}
+	} else if req.r.PostForm == nil {
+		err = req.r.ParseForm()
+	}
+
+	if errors.Is(err, ErrRequestBodyTooLarge) {
+		panic(ErrRequestBodyTooLarge)
+	}
+
+	if req.r.PostForm == nil {
+		return url.Values{}
+	}
+
+	return req.r.PostForm
+}
+
+// query return all parameters from url query string
+func (req *httpRequest) query() url.Values {
+	if req.queries == nil {
+		req.queries = req.r.URL.Query()
+	}
+
+	return req.queries
+}
+
+// formArray collect values for key, key[] and key[N] from form
+func formArray(form url.Values, key string) []string {
+	res := make([]string, 0)
+	res = append(res, form[key]...)
+	res = append(res, form[key+"[]"]...)
+
+	indexes := make([]int, 0)
+	indexedValues := make(map[int][]string)
+	for k, values := range form {
+		sub, ok := bracketKey(k, key)
+		if !ok {
+			continue
+		}
+
+		index, err := strconv.Atoi(sub)
+		if err != nil {
+			continue
+		}
+
+		indexes = append(indexes, index)
+		indexedValues[index] = values
+	}
+
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		res = append(res, indexedValues[index]...)
 	}
-	defer func() {
-		_ = tempFile.Close()
-	}()
 
-	if _, err := io.Copy(tempFile, file); err != nil {
+	return res
+}
+
+// formMap collect values for key[name] from form
+func formMap(form url.Values, key string) map[string]string {
+	res := make(map[string]string)
+	for k, values := range form {
+		sub, ok := bracketKey(k, key)
+		if !ok || sub == "" || len(values) == 0 {
+			continue
+		}
+
+		res[sub] = values[0]
+	}
+
+	return res
+}
+
+// bracketKey extract name from form key like key[name]
+func bracketKey(formKey string, key string) (string, bool) {
+	if !strings.HasPrefix(formKey, key+"[") || !strings.HasSuffix(formKey, "]") {
+		return "", false
+	}
+
+	return formKey[len(key)+1 : len(formKey)-1], true
+}
+
+// File Retrieving Uploaded Files
+func (req *httpRequest) File(key string) (*UploadedFile, error) {
+	if err := req.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+
+	file, header, err := req.r.FormFile(key)
+	if err != nil {
 		return nil, err
 	}
 
-	return &UploadedFile{
-		Header:   header,
-		SavePath: tempFile.Name(),
-	}, nil
+	_ = file.Close()
+
+	return req.saveUploadedFile(header)
 }
 
 // IsXMLHTTPRequest return whether the request is a ajax request
@@ -376,25 +739,66 @@ func (req *httpRequest) Method() string {
 }
 
 // Body return request body
+// if the request body exceeds the max body size, ErrRequestBodyTooLarge will be panic to framework
 func (req *httpRequest) Body() []byte {
+	body, err := req.loadBody()
+	if err == ErrRequestBodyTooLarge {
+		panic(err)
+	}
+
+	return body
+}
+
+// BodyReader return the request body as a stream without buffering it into memory
+// the stream can only be consumed once, after Body(or other methods parse the body) has been called,
+// it returns a reader of the buffered body instead
+func (req *httpRequest) BodyReader() io.ReadCloser {
+	return req.r.Body
+}
+
+// loadBody read the whole request body into memory once
+func (req *httpRequest) loadBody() ([]byte, error) {
 	req.bodyLoader.Do(func() {
-		body, _ := ioutil.ReadAll(req.r.Body)
+		body, err := ioutil.ReadAll(req.r.Body)
 		_ = req.r.Body.Close()
 		req.r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 
 		req.body = body
+		req.bodyErr = err
 	})
 
-	return req.body
+	return req.body, req.bodyErr
+}
+
+// limitedBody is a request body limited by http.MaxBytesReader
+// it converts the error of exceeding limit to ErrRequestBodyTooLarge
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func newLimitedBody(w http.ResponseWriter, body io.ReadCloser, limit int64) io.ReadCloser {
+	return &limitedBody{ReadCloser: http.MaxBytesReader(w, body, limit), limit: limit}
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.read += int64(n)
+	if err != nil && err != io.EOF && body.read >= body.limit {
+		return n, ErrRequestBodyTooLarge
+	}
+
+	return n, err
 }
 
 // Validate execute a validator, if there has an error, panic error to framework
 func (req *httpRequest) Validate(validator Validator, jsonResponse bool) {
 	if err := validator.Validate(req); err != nil {
 		if jsonResponse {
-			panic(WrapJSONError(fmt.Errorf("invalid request: %v", err), http.StatusUnprocessableEntity))
+			panic(WrapJSONError(errors.WithMessage(err, "invalid request"), http.StatusUnprocessableEntity))
 		} else {
-			panic(WrapPlainError(fmt.Errorf("invalid request: %v", err), http.StatusUnprocessableEntity))
+			panic(WrapPlainError(errors.WithMessage(err, "invalid request"), http.StatusUnprocessableEntity))
 		}
 	}
 }
@@ -403,6 +807,10 @@ func (req *httpRequest) Validate(validator Validator, jsonResponse bool) {
 type UploadedFile struct {
 	Header   *multipart.FileHeader
 	SavePath string
+
+	tempPath string
+	checksum string
+	mimeType string
 }
 
 // Extension get the file's extension.
@@ -411,9 +819,40 @@ func (file *UploadedFile) Extension() string {
 	return segs[len(segs)-1]
 }
 
+// MimeType return the mime type sniffed from file content, the Content-Type provided by client is not trusted
+func (file *UploadedFile) MimeType() string {
+	return file.mimeType
+}
+
+// GuessExtension return the extension guessed from sniffed mime type, if it can not be guessed, Extension will be returned
+func (file *UploadedFile) GuessExtension() string {
+	mimeType := strings.Split(file.mimeType, ";")[0]
+	if ext := mimeExtension(mimeType); ext != "" {
+		return ext
+	}
+
+	return file.Extension()
+}
+
+// Checksum return the hex encoded sha256 checksum of file content, it's computed during uploading
+func (file *UploadedFile) Checksum() string {
+	return file.checksum
+}
+
+// HashName return a filename composed by checksum and guessed extension
+func (file *UploadedFile) HashName() string {
+	ext := file.GuessExtension()
+	if ext == "" {
+		return file.checksum
+	}
+
+	return file.checksum + "." + ext
+}
+
 // Store store the uploaded file on a filesystem disk.
+// if the file can not be renamed(eg. across filesystems), it will be copied to the destination
 func (file *UploadedFile) Store(path string) error {
-	if err := os.Rename(file.SavePath, path); err != nil {
+	if err := moveFile(file.SavePath, path); err != nil {
 		return err
 	}
 
@@ -421,6 +860,20 @@ func (file *UploadedFile) Store(path string) error {
 	return nil
 }
 
+// StoreTo store the uploaded file to a storage backend, returns the path in storage
+func (file *UploadedFile) StoreTo(storage Storage, path string) (string, error) {
+	f, err := os.Open(file.SavePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return storage.Store(path, f)
+}
+
 // Delete 删除文件
 func (file *UploadedFile) Delete() error {
 	return os.Remove(file.SavePath)
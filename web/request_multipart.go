@@ -0,0 +1,326 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUploadFileTooLarge is the error when a uploaded file exceeds Config.MaxUploadFileSize
+var ErrUploadFileTooLarge = WrapPlainError(errors.New("uploaded file too large"), http.StatusRequestEntityTooLarge)
+
+// ErrUploadFileTypeNotAllowed is the error when the mime type of a uploaded file is not in Config.AllowedUploadMimeTypes
+var ErrUploadFileTypeNotAllowed = WrapPlainError(errors.New("uploaded file type not allowed"), http.StatusUnsupportedMediaType)
+
+// Files Retrieving all uploaded files for a multi-file field
+func (req *httpRequest) Files(key string) ([]*UploadedFile, error) {
+	if err := req.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+
+	headers := req.r.MultipartForm.File[key]
+	if len(headers) == 0 {
+		return nil, http.ErrMissingFile
+	}
+
+	files := make([]*UploadedFile, 0, len(headers))
+	for _, header := range headers {
+		file, err := req.saveUploadedFile(header)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// Multipart return a iterator which reads the multipart body as a stream
+// parts are not buffered into memory or temporary files, so it can be used to pipe large uploads to storage directly.
+// Multipart can not be used together with File, Files, Input or other methods that parse the request form
+func (req *httpRequest) Multipart() (*MultipartIterator, error) {
+	reader, err := req.r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartIterator{reader: reader, conf: req.conf}, nil
+}
+
+// parseMultipartForm parse the request body as multipart form once
+// ErrRequestBodyTooLarge will be returned as is if the request body exceeds the max body size
+func (req *httpRequest) parseMultipartForm() error {
+	if req.r.MultipartForm != nil {
+		return nil
+	}
+
+	if err := req.r.ParseMultipartForm(req.conf.MultipartFormMaxMemory); err != nil {
+		if errors.Is(err, ErrRequestBodyTooLarge) {
+			return ErrRequestBodyTooLarge
+		}
+
+		return errors.Wrap(err, "parse multipart form failed")
+	}
+
+	return nil
+}
+
+// saveUploadedFile check the uploaded file against upload limits and copy it to a temporary file
+// checksum and mime type of the file are computed during copying
+// the temporary file will be removed when the request finished if it's not stored to other place
+func (req *httpRequest) saveUploadedFile(header *multipart.FileHeader) (*UploadedFile, error) {
+	if req.conf.MaxUploadFileSize > 0 && header.Size > req.conf.MaxUploadFileSize {
+		return nil, ErrUploadFileTooLarge
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	tempFile, err := ioutil.TempFile(req.conf.TempDir, req.conf.TempFilePattern)
+	if err != nil {
+		return nil, fmt.Errorf("can not create temporary file %s", err.Error())
+	}
+	defer func() {
+		_ = tempFile.Close()
+	}()
+
+	uploadedFile := &UploadedFile{
+		Header:   header,
+		SavePath: tempFile.Name(),
+		tempPath: tempFile.Name(),
+	}
+	req.tempFiles = append(req.tempFiles, uploadedFile)
+
+	hash := sha256.New()
+	sniffer := &sniffWriter{}
+	if _, err := io.Copy(io.MultiWriter(tempFile, hash, sniffer), file); err != nil {
+		return nil, err
+	}
+
+	uploadedFile.checksum = hex.EncodeToString(hash.Sum(nil))
+	uploadedFile.mimeType = http.DetectContentType(sniffer.buf)
+
+	if !mimeTypeAllowed(uploadedFile.mimeType, req.conf.AllowedUploadMimeTypes) {
+		return nil, ErrUploadFileTypeNotAllowed
+	}
+
+	return uploadedFile, nil
+}
+
+// sniffWriter keeps the leading bytes written to it for content type detection
+type sniffWriter struct {
+	buf []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if remain := sniffLen - len(w.buf); remain > 0 {
+		if len(p) < remain {
+			remain = len(p)
+		}
+
+		w.buf = append(w.buf, p[:remain]...)
+	}
+
+	return len(p), nil
+}
+
+// sniffLen is the max bytes used by http.DetectContentType
+const sniffLen = 512
+
+// cleanup remove all temporary files created for current request and close resources bound to it
+// if there are child requests still running, the cleanup happens after they finished
+func (req *httpRequest) cleanup() {
+	if atomic.LoadInt32(&req.running) > 0 {
+		go func() {
+			req.forks.Wait()
+			req.dispose()
+		}()
+		return
+	}
+
+	req.dispose()
+}
+
+// closeOnCleanup bind closer to the request, it will be closed when the request finished
+func (req *httpRequest) closeOnCleanup(closer io.Closer) {
+	req.closers = append(req.closers, closer)
+}
+
+func (req *httpRequest) dispose() {
+	for _, closer := range req.closers {
+		_ = closer.Close()
+	}
+
+	req.closers = nil
+
+	for _, file := range req.tempFiles {
+		if file.SavePath == file.tempPath {
+			_ = os.Remove(file.tempPath)
+		}
+	}
+
+	req.tempFiles = nil
+
+	if req.r.MultipartForm != nil {
+		_ = req.r.MultipartForm.RemoveAll()
+	}
+
+	for _, form := range req.forms {
+		_ = form.RemoveAll()
+	}
+
+	req.forms = nil
+}
+
+// fork create a child request with ctx, which can be used in another goroutine
+// the child has its own stores and temporary files, release must be called when the child is not used any more
+func (req *httpRequest) fork(ctx context.Context) *httpRequest {
+	child := NewRequest(req.cc, req.conf, req.r.WithContext(ctx), req.pathVars).(*httpRequest)
+	for k, v := range req.stores {
+		child.stores[k] = v
+	}
+
+	if req.body != nil || req.bodyErr != nil {
+		child.r.Body = ioutil.NopCloser(bytes.NewReader(req.body))
+	}
+
+	atomic.AddInt32(&req.running, 1)
+	req.forks.Add(1)
+
+	return child
+}
+
+// join merge the stores, temporary files and resources of a finished child request back, and release it
+func (req *httpRequest) join(child *httpRequest) {
+	for k, v := range child.stores {
+		req.stores[k] = v
+	}
+
+	req.tempFiles = append(req.tempFiles, child.tempFiles...)
+	child.tempFiles = nil
+
+	req.closers = append(req.closers, child.closers...)
+	child.closers = nil
+
+	if child.r.MultipartForm != nil && child.r.MultipartForm != req.r.MultipartForm {
+		req.forms = append(req.forms, child.r.MultipartForm)
+	}
+
+	atomic.AddInt32(&req.running, -1)
+	req.forks.Done()
+}
+
+// release clean up a abandoned child request and release it
+func (req *httpRequest) release(child *httpRequest) {
+	child.dispose()
+	atomic.AddInt32(&req.running, -1)
+	req.forks.Done()
+}
+
+// MultipartIterator is a iterator for reading multipart parts as a stream
+type MultipartIterator struct {
+	reader *multipart.Reader
+	conf   *Config
+}
+
+// Next return the next part in multipart body, io.EOF will be returned if there are no more parts
+// the content of previous part will be discarded
+func (it *MultipartIterator) Next() (*MultipartPart, error) {
+	part, err := it.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+
+	mp := &MultipartPart{Part: part, reader: bufio.NewReaderSize(part, sniffLen), limit: it.conf.MaxUploadFileSize}
+	if mp.IsFile() {
+		head, _ := mp.reader.Peek(sniffLen)
+		mp.mimeType = http.DetectContentType(head)
+
+		if !mimeTypeAllowed(mp.mimeType, it.conf.AllowedUploadMimeTypes) {
+			return nil, ErrUploadFileTypeNotAllowed
+		}
+	}
+
+	return mp, nil
+}
+
+// MultipartPart is a part in multipart body
+type MultipartPart struct {
+	*multipart.Part
+	reader   *bufio.Reader
+	mimeType string
+	limit    int64
+	read     int64
+}
+
+// IsFile return whether the part is a file
+func (part *MultipartPart) IsFile() bool {
+	return part.FileName() != ""
+}
+
+// ContentType return the content type of the part provided by client
+func (part *MultipartPart) ContentType() string {
+	return part.Header.Get("Content-Type")
+}
+
+// MimeType return the mime type sniffed from content for a file part
+func (part *MultipartPart) MimeType() string {
+	return part.mimeType
+}
+
+// Read reads the body of a part, ErrUploadFileTooLarge will be returned if a file part exceeds Config.MaxUploadFileSize
+func (part *MultipartPart) Read(p []byte) (int, error) {
+	n, err := part.reader.Read(p)
+	part.read += int64(n)
+	if part.limit > 0 && part.IsFile() && part.read > part.limit {
+		return n, ErrUploadFileTooLarge
+	}
+
+	return n, err
+}
+
+// mimeTypeAllowed return whether the content type matches one of allowed mime types
+// allowed mime types support wildcard like image/*, an empty allowed list means all types are allowed
+func mimeTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*/*" || a == mediaType {
+			return true
+		}
+
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,208 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+var pngContent = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+type multipartTestFile struct {
+	field   string
+	name    string
+	content []byte
+}
+
+func newMultipartRequest(fields map[string]string, files []multipartTestFile) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+
+	for _, f := range files {
+		part, _ := w.CreateFormFile(f.field, f.name)
+		_, _ = part.Write(f.content)
+	}
+	_ = w.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+
+	return r
+}
+
+func TestUploadedFiles(t *testing.T) {
+	checksum := sha256.Sum256(pngContent)
+
+	testCases := []struct {
+		name    string
+		maxSize int64
+		allowed []string
+		files   []multipartTestFile
+		code    int
+		body    string
+	}{
+		{
+			name:  "files",
+			files: []multipartTestFile{{field: "avatar", name: "a.png", content: pngContent}, {field: "avatar", name: "b.txt", content: []byte("hello")}},
+			code:  http.StatusOK,
+			body:  fmt.Sprintf("a.png image/png %x\nb.txt text/plain; charset=utf-8 %x\n", checksum, sha256.Sum256([]byte("hello"))),
+		},
+		{
+			name:    "allowed mime type",
+			allowed: []string{"image/*"},
+			files:   []multipartTestFile{{field: "avatar", name: "a.png", content: pngContent}},
+			code:    http.StatusOK,
+			body:    fmt.Sprintf("a.png image/png %x\n", checksum),
+		},
+		{
+			name:    "mime type is sniffed from content",
+			allowed: []string{"image/*"},
+			files:   []multipartTestFile{{field: "avatar", name: "a.png", content: []byte("hello")}},
+			code:    http.StatusUnsupportedMediaType,
+			body:    "uploaded file type not allowed",
+		},
+		{
+			name:    "file too large",
+			maxSize: 4,
+			files:   []multipartTestFile{{field: "avatar", name: "a.png", content: pngContent}},
+			code:    http.StatusRequestEntityTooLarge,
+			body:    "uploaded file too large",
+		},
+		{
+			name: "missing file",
+			code: http.StatusInternalServerError,
+			body: http.ErrMissingFile.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.TempDir = os.TempDir()
+		conf.MaxUploadFileSize = tc.maxSize
+		conf.AllowedUploadMimeTypes = tc.allowed
+
+		var tempFiles []string
+		router := NewRouter(container.New(), conf)
+		router.Post("/", func(ctx Context) (string, error) {
+			files, err := ctx.Files("avatar")
+			if err != nil {
+				return "", err
+			}
+
+			var sb strings.Builder
+			for _, f := range files {
+				tempFiles = append(tempFiles, f.GetTempFilename())
+				sb.WriteString(fmt.Sprintf("%s %s %s\n", f.Name(), f.MimeType(), f.Checksum()))
+			}
+
+			return sb.String(), nil
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newMultipartRequest(map[string]string{"name": "router"}, tc.files))
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+
+		// temporary files are removed after the request finished
+		for _, f := range tempFiles {
+			if _, err := os.Stat(f); !os.IsNotExist(err) {
+				t.Errorf("%s: expect temporary file %s removed", tc.name, f)
+			}
+		}
+	}
+}
+
+func TestMultipartIterator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		maxSize int64
+		allowed []string
+		files   []multipartTestFile
+		expect  string
+	}{
+		{
+			name:   "parts",
+			files:  []multipartTestFile{{field: "avatar", name: "a.png", content: pngContent}},
+			expect: fmt.Sprintf("name=router\navatar=a.png image/png %d\n", len(pngContent)),
+		},
+		{
+			name:    "file type not allowed",
+			allowed: []string{"image/*"},
+			files:   []multipartTestFile{{field: "doc", name: "a.txt", content: []byte("hello")}},
+			expect:  "name=router\n" + ErrUploadFileTypeNotAllowed.Error(),
+		},
+		{
+			name:    "file too large",
+			maxSize: 4,
+			files:   []multipartTestFile{{field: "avatar", name: "a.png", content: pngContent}},
+			expect:  "name=router\n" + ErrUploadFileTooLarge.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.MaxUploadFileSize = tc.maxSize
+		conf.AllowedUploadMimeTypes = tc.allowed
+
+		var sb strings.Builder
+		router := NewRouter(container.New(), conf)
+		router.Post("/", func(ctx Context) string {
+			it, err := ctx.Multipart()
+			if err != nil {
+				return err.Error()
+			}
+
+			for {
+				part, err := it.Next()
+				if err == io.EOF {
+					break
+				}
+
+				if err != nil {
+					sb.WriteString(err.Error())
+					break
+				}
+
+				data, err := ioutil.ReadAll(part)
+				if err != nil {
+					sb.WriteString(err.Error())
+					break
+				}
+
+				if part.IsFile() {
+					sb.WriteString(fmt.Sprintf("%s=%s %s %d\n", part.FormName(), part.FileName(), part.MimeType(), len(data)))
+				} else {
+					sb.WriteString(fmt.Sprintf("%s=%s\n", part.FormName(), data))
+				}
+			}
+
+			return sb.String()
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newMultipartRequest(map[string]string{"name": "router"}, tc.files))
+
+		if w.Body.String() != tc.expect {
+			t.Errorf("%s: expect %q, got %q", tc.name, tc.expect, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+func newJSONRequest(body string) Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	return NewRequest(container.New(), DefaultConfig(), r, nil)
+}
+
+func TestJSONValues(t *testing.T) {
+	req := newJSONRequest(`{
+		"id": 16777217,
+		"big": 9007199254740993,
+		"price": 1.25,
+		"ids": [16777217, 9007199254740993, "3"],
+		"user": {"name": "router", "tags": ["a", "b"]},
+		"matrix": [[1, 2], [3]],
+		"ok": true,
+		"nothing": null
+	}`)
+
+	testCases := []struct {
+		name   string
+		actual interface{}
+		expect interface{}
+	}{
+		{name: "int above float32 precision", actual: req.JSONGet("id"), expect: "16777217"},
+		{name: "int above float64 precision", actual: req.JSONGet("big"), expect: "9007199254740993"},
+		{name: "float", actual: req.JSONGet("price"), expect: "1.25"},
+		{name: "object", actual: req.JSONGet("user"), expect: `{"name": "router", "tags": ["a", "b"]}`},
+		{name: "nested value", actual: req.JSONGet("user", "name"), expect: "router"},
+		{name: "array", actual: req.JSONGet("user", "tags"), expect: `["a", "b"]`},
+		{name: "boolean", actual: req.JSONGet("ok"), expect: "true"},
+		{name: "null", actual: req.JSONGet("nothing"), expect: ""},
+		{name: "missing", actual: req.JSONGet("missing"), expect: ""},
+		{name: "int array", actual: req.IntArray("ids"), expect: []int{16777217, 9007199254740993, 3}},
+		{name: "int64 array", actual: req.Int64Array("ids"), expect: []int64{16777217, 9007199254740993, 3}},
+		{name: "array of arrays", actual: req.InputArray("matrix"), expect: []string{"[1, 2]", "[3]"}},
+		{name: "map", actual: req.InputMap("user"), expect: map[string]string{"name": "router", "tags": `["a", "b"]`}},
+	}
+
+	for _, tc := range testCases {
+		if !reflect.DeepEqual(tc.actual, tc.expect) {
+			t.Errorf("%s: expect %#v, got %#v", tc.name, tc.expect, tc.actual)
+		}
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	testCases := []struct {
+		name  string
+		limit int64
+		body  string
+		code  int
+	}{
+		{name: "global limit", body: "0123456789", code: http.StatusRequestEntityTooLarge},
+		{name: "global limit not exceeded", body: "0123", code: http.StatusOK},
+		{name: "route limit", limit: 16, body: "0123456789", code: http.StatusOK},
+		{name: "route limit exceeded", limit: 2, body: "0123", code: http.StatusRequestEntityTooLarge},
+		{name: "unlimited route", limit: UnlimitedBodySize, body: "0123456789", code: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.MaxBodySize = 4
+
+		router := NewRouter(container.New(), conf)
+		router.Post("/", func(ctx Context) string {
+			return string(ctx.Body())
+		}).WithMaxBodySize(tc.limit)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)))
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+	}
+}
+
+func TestBodyReaderAfterBody(t *testing.T) {
+	req := newJSONRequest(`{"id": 1}`)
+
+	if body := string(req.Body()); body != `{"id": 1}` {
+		t.Fatalf("expect body %q, got %q", `{"id": 1}`, body)
+	}
+
+	data, err := ioutil.ReadAll(req.BodyReader())
+	if err != nil || string(data) != `{"id": 1}` {
+		t.Errorf("expect buffered body from BodyReader, got %q %v", data, err)
+	}
+}
@@ -1,20 +1,33 @@
 package web
 
-import "net/http"
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
 
 // simpleResponser is a response object which wrap http.ResponseWriter
 type simpleResponser struct {
-	w        http.ResponseWriter
-	headers  map[string][]string
-	cookie   *http.Cookie
-	original []byte
-	code     int
+	w          http.ResponseWriter
+	headers    http.Header
+	delHeaders []string
+	cookies    []*http.Cookie
+	original   []byte
+	code       int
+
+	beforeFlush []func()
+
+	headerFlushed bool
+	hijacked      bool
 }
 
 func NewResponseCreator(w http.ResponseWriter) Responsor {
 	return &simpleResponser{
 		w:       w,
-		headers: make(map[string][]string),
+		headers: make(http.Header),
+		cookies: make([]*http.Cookie, 0),
 	}
 }
 
@@ -42,36 +55,198 @@ func (resp *simpleResponser) SetContent(content []byte) {
 	resp.original = content
 }
 
-// Header set response header
+// GetContent get the buffered response content
+func (resp *simpleResponser) GetContent() []byte {
+	return resp.original
+}
+
+// BeforeFlush register a callback which will be called before buffered response is flushed to client
+// callbacks can modify the code, headers and content, they are called in registration order,
+// callbacks registered while flushing are called after the current ones
+// streaming responses which only call FlushHeader don't trigger callbacks
+func (resp *simpleResponser) BeforeFlush(fn func()) {
+	resp.beforeFlush = append(resp.beforeFlush, fn)
+}
+
+// Header set response header, it's same as SetHeader
 func (resp *simpleResponser) Header(key string, values ...string) {
-	resp.headers[key] = values
+	resp.SetHeader(key, values...)
+}
+
+// SetHeader set response header, replacing any existing values, including the one set to http.ResponseWriter directly
+func (resp *simpleResponser) SetHeader(key string, values ...string) {
+	resp.DelHeader(key)
+	resp.AddHeader(key, values...)
 }
 
-// Cookie set cookie
+// AddHeader append values to response header, values set to http.ResponseWriter directly are kept
+func (resp *simpleResponser) AddHeader(key string, values ...string) {
+	for _, v := range values {
+		resp.headers.Add(key, v)
+	}
+}
+
+// DelHeader delete a response header, including the one set to http.ResponseWriter directly
+func (resp *simpleResponser) DelHeader(key string) {
+	resp.headers.Del(key)
+
+	key = http.CanonicalHeaderKey(key)
+	if !stringIn(key, resp.delHeaders) {
+		resp.delHeaders = append(resp.delHeaders, key)
+	}
+}
+
+// GetHeader gets the first value of a response header
+func (resp *simpleResponser) GetHeader(key string) string {
+	return resp.headers.Get(key)
+}
+
+// Headers return all response headers
+func (resp *simpleResponser) Headers() http.Header {
+	return resp.headers
+}
+
+// Cookie set cookie, cookies with same name, path and domain will be replaced
 func (resp *simpleResponser) Cookie(cookie *http.Cookie) {
-	// http.SetCookie(resp.w, cookie)
-	resp.cookie = cookie
+	for i, c := range resp.cookies {
+		if c.Name == cookie.Name && c.Path == cookie.Path && c.Domain == cookie.Domain {
+			resp.cookies[i] = cookie
+			return
+		}
+	}
+
+	resp.cookies = append(resp.cookies, cookie)
+}
+
+// ClearCookie ask the client to remove a cookie
+func (resp *simpleResponser) ClearCookie(name string, path string) {
+	resp.Cookie(&http.Cookie{
+		Name:    name,
+		Path:    path,
+		MaxAge:  -1,
+		Expires: time.Unix(0, 0),
+	})
+}
+
+// Cookies return all cookies to be sent
+func (resp *simpleResponser) Cookies() []*http.Cookie {
+	return resp.cookies
+}
+
+// Hijack let the caller take over the connection, the response will not be sent after hijacked
+func (resp *simpleResponser) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := resp.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp.hijacked = true
+	return conn, rw, nil
 }
 
 // Flush send all response contents to client
 func (resp *simpleResponser) Flush() {
+	if resp.hijacked {
+		return
+	}
+
+	// callbacks registered by callbacks are called after all the current ones
+	for !resp.headerFlushed && len(resp.beforeFlush) > 0 {
+		callbacks := resp.beforeFlush
+		resp.beforeFlush = nil
+		for _, cb := range callbacks {
+			cb()
+		}
+	}
+
+	resp.FlushHeader()
+
+	// send response body
+	_, _ = resp.w.Write(resp.original)
+}
+
+// FlushHeader send response code, headers and cookies to client without body
+// it's used by streaming responses, the header will only be sent once
+func (resp *simpleResponser) FlushHeader() {
+	if resp.headerFlushed || resp.hijacked {
+		return
+	}
+
+	resp.headerFlushed = true
+
 	// set response headers
-	for key, value := range resp.headers {
-		for _, v := range value {
+	for _, key := range resp.delHeaders {
+		resp.w.Header().Del(key)
+	}
+
+	// headers set to http.ResponseWriter directly(eg. by middlewares) are kept unless they are replaced or deleted by responsor
+	for key, values := range resp.headers {
+		for _, v := range values {
 			resp.w.Header().Add(key, v)
 		}
 	}
 
 	// set cookies
-	if resp.cookie != nil {
-		http.SetCookie(resp.w, resp.cookie)
+	for _, cookie := range resp.cookies {
+		http.SetCookie(resp.w, cookie)
 	}
 
 	// set response code
 	resp.w.WriteHeader(resp.code)
+}
 
-	// send response body
-	_, _ = resp.w.Write(resp.original)
+// responsorState is a snapshot of the buffered state of simpleResponser
+type responsorState struct {
+	headers     http.Header
+	delHeaders  []string
+	cookies     []*http.Cookie
+	original    []byte
+	code        int
+	beforeFlush []func()
+}
+
+// snapshot save the buffered state, it can be restored by restore
+func (resp *simpleResponser) snapshot() responsorState {
+	return responsorState{
+		headers:     resp.headers.Clone(),
+		delHeaders:  append([]string(nil), resp.delHeaders...),
+		cookies:     append([]*http.Cookie(nil), resp.cookies...),
+		original:    resp.original,
+		code:        resp.code,
+		beforeFlush: append([]func(){}, resp.beforeFlush...),
+	}
+}
+
+// restore discard all changes after the snapshot was taken
+func (resp *simpleResponser) restore(state responsorState) {
+	resp.headers = state.headers
+	resp.delHeaders = state.delHeaders
+	resp.cookies = state.cookies
+	resp.original = state.original
+	resp.code = state.code
+	resp.beforeFlush = state.beforeFlush
+}
+
+// NewCookie create a cookie for path / which expires after ttl, ttl <= 0 means a session cookie
+func NewCookie(name string, value string, ttl time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+	}
+
+	if ttl > 0 {
+		cookie.MaxAge = int(ttl.Seconds())
+		cookie.Expires = time.Now().Add(ttl)
+	}
+
+	return cookie
 }
 
 // M represents a kv response items
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"fmt"
+)
+
+// CodecResponse is a response encoded by a codec from CodecRegistry
+type CodecResponse struct {
+	response  Responsor
+	codecs    *CodecRegistry
+	mediaType string
+	original  interface{}
+	code      int
+}
+
+func (resp *CodecResponse) Code() int {
+	return resp.code
+}
+
+// NewCodecResponse create a CodecResponse which encodes res with the codec for mediaType
+func NewCodecResponse(response Responsor, codecs *CodecRegistry, mediaType string, code int, res interface{}) *CodecResponse {
+	return &CodecResponse{
+		response:  response,
+		codecs:    codecs,
+		mediaType: mediaType,
+		original:  res,
+		code:      code,
+	}
+}
+
+// WithCode set response code and return itself
+func (resp *CodecResponse) WithCode(code int) *CodecResponse {
+	resp.code = code
+	return resp
+}
+
+// Send create response
+func (resp *CodecResponse) Send() error {
+	codec, ok := resp.codecs.Get(resp.mediaType)
+	if !ok {
+		return fmt.Errorf("no codec registered for media type %s", resp.mediaType)
+	}
+
+	res, err := codec.Marshal(resp.original)
+	if err != nil {
+		return fmt.Errorf("%s encode failed: %v [%v]", resp.mediaType, err, resp.original)
+	}
+
+	resp.response.SetCode(resp.code)
+	resp.response.Header("Content-Type", codec.ContentType())
+	resp.response.SetContent(res)
+
+	resp.response.Flush()
+	return nil
+}
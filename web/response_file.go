@@ -0,0 +1,256 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileResponse is a response which serves file content with Range and conditional request support
+type FileResponse struct {
+	response Responsor
+	request  Request
+	open     func() (io.ReadSeeker, os.FileInfo, error)
+
+	name        string
+	modTime     time.Time
+	contentType string
+	disposition string
+	filename    string
+	etag        string
+	code        int
+	callbacks   []func(resp Response, err error)
+}
+
+// NewFileResponse create a FileResponse which serves file from path
+func NewFileResponse(response Responsor, request Request, path string) *FileResponse {
+	return newFileResponse(response, request, filepath.Base(path), func() (io.ReadSeeker, os.FileInfo, error) {
+		return openFile(func() (http.File, error) { return os.Open(path) })
+	})
+}
+
+// NewFileSystemResponse create a FileResponse which serves file from a http.FileSystem
+func NewFileSystemResponse(response Responsor, request Request, fs http.FileSystem, name string) *FileResponse {
+	return newFileResponse(response, request, filepath.Base(name), func() (io.ReadSeeker, os.FileInfo, error) {
+		return openFile(func() (http.File, error) { return fs.Open(name) })
+	})
+}
+
+// NewReadSeekerResponse create a FileResponse which serves content from a io.ReadSeeker
+// name is used for detecting content type, modTime is used for Last-Modified and can be zero
+func NewReadSeekerResponse(response Responsor, request Request, content io.ReadSeeker, name string, modTime time.Time) *FileResponse {
+	resp := newFileResponse(response, request, name, func() (io.ReadSeeker, os.FileInfo, error) {
+		return content, nil, nil
+	})
+	resp.modTime = modTime
+
+	return resp
+}
+
+func newFileResponse(response Responsor, request Request, name string, open func() (io.ReadSeeker, os.FileInfo, error)) *FileResponse {
+	return &FileResponse{
+		response:  response,
+		request:   request,
+		open:      open,
+		name:      name,
+		code:      http.StatusOK,
+		callbacks: make([]func(resp Response, err error), 0),
+	}
+}
+
+// openFile open a file and get its stat, directories are not allowed
+func openFile(open func() (http.File, error)) (io.ReadSeeker, os.FileInfo, error) {
+	f, err := open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+
+	if stat.IsDir() {
+		_ = f.Close()
+		return nil, nil, os.ErrNotExist
+	}
+
+	return f, stat, nil
+}
+
+// Code return the response code, after the response has been sent, it's the status code sent to client(eg. 206, 304)
+func (resp *FileResponse) Code() int {
+	return resp.code
+}
+
+// OnSent register a callback which will be called after the file has been sent
+func (resp *FileResponse) OnSent(fn func(resp Response, err error)) {
+	resp.callbacks = append(resp.callbacks, fn)
+}
+
+// WithContentType set the Content-Type header, it's detected from name or content by default
+func (resp *FileResponse) WithContentType(contentType string) *FileResponse {
+	resp.contentType = contentType
+	return resp
+}
+
+// WithETag set the ETag header, an ETag is generated from size and modification time for files by default
+func (resp *FileResponse) WithETag(etag string) *FileResponse {
+	resp.etag = etag
+	return resp
+}
+
+// WithModTime set the modification time used for Last-Modified header
+func (resp *FileResponse) WithModTime(modTime time.Time) *FileResponse {
+	resp.modTime = modTime
+	return resp
+}
+
+// Inline set Content-Disposition to inline with filename
+func (resp *FileResponse) Inline(filename string) *FileResponse {
+	resp.disposition = "inline"
+	resp.filename = filename
+	return resp
+}
+
+// Attachment set Content-Disposition to attachment with filename, browsers will download the file
+func (resp *FileResponse) Attachment(filename string) *FileResponse {
+	resp.disposition = "attachment"
+	resp.filename = filename
+	return resp
+}
+
+// Send serve the file content, Range, If-Range, If-Match, If-None-Match,
+// If-Modified-Since and If-Unmodified-Since are handled by http.ServeContent
+func (resp *FileResponse) Send() (err error) {
+	defer func() {
+		for _, cb := range resp.callbacks {
+			cb(resp, err)
+		}
+	}()
+
+	content, stat, err := resp.open()
+	if err != nil {
+		code := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			code = http.StatusNotFound
+		} else if os.IsPermission(err) {
+			code = http.StatusForbidden
+		}
+
+		resp.code = code
+		return NewErrorResponse(resp.response, http.StatusText(code), code).Send()
+	}
+
+	if closer, ok := content.(io.Closer); ok {
+		defer func() {
+			_ = closer.Close()
+		}()
+	}
+
+	modTime := resp.modTime
+	if modTime.IsZero() && stat != nil {
+		modTime = stat.ModTime()
+	}
+
+	if resp.contentType != "" {
+		resp.response.Header("Content-Type", resp.contentType)
+	}
+
+	if resp.disposition != "" {
+		resp.response.Header("Content-Disposition", contentDisposition(resp.disposition, resp.filename))
+	}
+
+	if resp.etag != "" {
+		resp.response.Header("ETag", resp.etag)
+	} else if stat != nil && resp.response.GetHeader("ETag") == "" {
+		resp.response.Header("ETag", fmt.Sprintf(`"%x-%x"`, stat.Size(), modTime.UnixNano()))
+	}
+
+	writer := &responsorWriter{response: resp.response}
+	http.ServeContent(writer, resp.request.Raw(), resp.name, modTime, content)
+	resp.code = resp.response.GetCode()
+
+	return writer.err
+}
+
+// responsorWriter is a http.ResponseWriter which writes header through Responsor
+// so the headers and cookies set to Responsor are sent
+type responsorWriter struct {
+	response    Responsor
+	wroteHeader bool
+	err         error
+}
+
+func (w *responsorWriter) Header() http.Header {
+	return w.response.Headers()
+}
+
+func (w *responsorWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.response.SetCode(code)
+	w.response.FlushHeader()
+}
+
+func (w *responsorWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.response.Raw().Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+// contentDisposition create a Content-Disposition header value, filename is encoded as RFC 6266
+func contentDisposition(disposition string, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+
+	fallback := make([]byte, 0, len(filename))
+	ascii := true
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			ascii = false
+			fallback = append(fallback, '_')
+			continue
+		}
+
+		fallback = append(fallback, byte(r))
+	}
+
+	value := fmt.Sprintf(`%s; filename="%s"`, disposition, fallback)
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+
+	return value
+}
+
+// encodeRFC5987 percent-encode a string except attr-char defined in RFC 5987
+func encodeRFC5987(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			sb.WriteByte(b)
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("%%%02X", b))
+	}
+
+	return sb.String()
+}
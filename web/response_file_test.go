@@ -0,0 +1,199 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+func TestFileResponse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-response-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hello.txt")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastModified := modTime.Format(http.TimeFormat)
+
+	router := NewRouter(container.New(), DefaultConfig())
+	router.Add([]string{http.MethodGet, http.MethodHead}, "/file", func(ctx Context) Response {
+		return ctx.ServeFile(path).WithETag(`"v1"`).WithModTime(modTime)
+	})
+	router.Get("/download", func(ctx Context) Response {
+		return ctx.Download(path, "报告 2020.txt")
+	})
+	router.Get("/inline", func(ctx Context) Response {
+		return ctx.ServeFile(path).Inline("hello.txt")
+	})
+	router.Get("/missing", func(ctx Context) Response {
+		return ctx.ServeFile(filepath.Join(dir, "missing.txt"))
+	})
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		code   int
+		body   string
+		expect map[string]string
+	}{
+		{
+			name: "full content",
+			path: "/file",
+			code: http.StatusOK,
+			body: "0123456789",
+			expect: map[string]string{
+				"Content-Length": "10",
+				"Content-Type":   "text/plain; charset=utf-8",
+				"Accept-Ranges":  "bytes",
+				"ETag":           `"v1"`,
+				"Last-Modified":  lastModified,
+			},
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			path:   "/file",
+			code:   http.StatusOK,
+			body:   "",
+			expect: map[string]string{"Content-Length": "10", "ETag": `"v1"`},
+		},
+		{
+			name:   "range",
+			path:   "/file",
+			header: map[string]string{"Range": "bytes=2-5"},
+			code:   http.StatusPartialContent,
+			body:   "2345",
+			expect: map[string]string{"Content-Range": "bytes 2-5/10", "Content-Length": "4"},
+		},
+		{
+			name:   "suffix range",
+			path:   "/file",
+			header: map[string]string{"Range": "bytes=-3"},
+			code:   http.StatusPartialContent,
+			body:   "789",
+			expect: map[string]string{"Content-Range": "bytes 7-9/10"},
+		},
+		{
+			name:   "unsatisfiable range",
+			path:   "/file",
+			header: map[string]string{"Range": "bytes=20-30"},
+			code:   http.StatusRequestedRangeNotSatisfiable,
+			expect: map[string]string{"Content-Range": "bytes */10"},
+		},
+		{
+			name:   "if-range matched",
+			path:   "/file",
+			header: map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`},
+			code:   http.StatusPartialContent,
+			body:   "01",
+		},
+		{
+			name:   "if-range not matched",
+			path:   "/file",
+			header: map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`},
+			code:   http.StatusOK,
+			body:   "0123456789",
+		},
+		{
+			name:   "if-none-match",
+			path:   "/file",
+			header: map[string]string{"If-None-Match": `"v0", "v1"`},
+			code:   http.StatusNotModified,
+			expect: map[string]string{"ETag": `"v1"`},
+		},
+		{
+			name:   "if-none-match not matched",
+			path:   "/file",
+			header: map[string]string{"If-None-Match": `"v0"`},
+			code:   http.StatusOK,
+			body:   "0123456789",
+		},
+		{
+			name:   "if-modified-since",
+			path:   "/file",
+			header: map[string]string{"If-Modified-Since": lastModified},
+			code:   http.StatusNotModified,
+		},
+		{
+			name:   "if-modified-since before modification",
+			path:   "/file",
+			header: map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			code:   http.StatusOK,
+			body:   "0123456789",
+		},
+		{
+			name:   "if-match not matched",
+			path:   "/file",
+			header: map[string]string{"If-Match": `"v0"`},
+			code:   http.StatusPreconditionFailed,
+		},
+		{
+			name:   "if-unmodified-since before modification",
+			path:   "/file",
+			header: map[string]string{"If-Unmodified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			code:   http.StatusPreconditionFailed,
+		},
+		{
+			name:   "attachment",
+			path:   "/download",
+			code:   http.StatusOK,
+			body:   "0123456789",
+			expect: map[string]string{"Content-Disposition": `attachment; filename="__ 2020.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%202020.txt`},
+		},
+		{
+			name:   "inline",
+			path:   "/inline",
+			code:   http.StatusOK,
+			body:   "0123456789",
+			expect: map[string]string{"Content-Disposition": `inline; filename="hello.txt"`},
+		},
+		{
+			name: "not found",
+			path: "/missing",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		method := tc.method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		req := httptest.NewRequest(method, tc.path, nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, w.Code)
+		}
+
+		if tc.code != http.StatusNotFound && tc.code != http.StatusRequestedRangeNotSatisfiable && w.Body.String() != tc.body {
+			t.Errorf("%s: expect body %q, got %q", tc.name, tc.body, w.Body.String())
+		}
+
+		for k, v := range tc.expect {
+			if w.Header().Get(k) != v {
+				t.Errorf("%s: expect header %s=%q, got %q", tc.name, k, v, w.Header().Get(k))
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NegotiateResponse is a response encoded by the codec chosen from Accept header
+type NegotiateResponse struct {
+	response  Responsor
+	codec     Codec
+	mediaType string
+	body      []byte
+	code      int
+}
+
+// Code return response code, 406 will be returned if there is no acceptable codec
+func (resp *NegotiateResponse) Code() int {
+	if resp.codec == nil {
+		return http.StatusNotAcceptable
+	}
+
+	return resp.code
+}
+
+// NewNegotiateResponse create a NegotiateResponse, res is encoded by the most preferred codec in accept which can encode it
+// if none of the acceptable codecs can encode res, a Error with status 500 will be panic to framework
+func NewNegotiateResponse(response Responsor, codecs *CodecRegistry, accept string, code int, res interface{}) *NegotiateResponse {
+	resp := &NegotiateResponse{
+		response: response,
+		code:     code,
+	}
+
+	var encodeErr error
+	for _, mediaType := range acceptableMediaTypes(accept, codecs.MediaTypes()) {
+		codec, ok := codecs.Get(mediaType)
+		if !ok {
+			continue
+		}
+
+		body, err := codec.Marshal(res)
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = fmt.Errorf("%s encode failed: %v", mediaType, err)
+			}
+
+			continue
+		}
+
+		resp.mediaType, resp.codec, resp.body = mediaType, codec, body
+		return resp
+	}
+
+	if encodeErr != nil {
+		panic(WrapPlainError(encodeErr, http.StatusInternalServerError))
+	}
+
+	return resp
+}
+
+// WithCode set response code and return itself
+func (resp *NegotiateResponse) WithCode(code int) *NegotiateResponse {
+	resp.code = code
+	return resp
+}
+
+// MediaType return the negotiated media type, it's empty if there is no acceptable codec
+func (resp *NegotiateResponse) MediaType() string {
+	return resp.mediaType
+}
+
+// Send create response
+func (resp *NegotiateResponse) Send() error {
+	resp.response.AddHeader("Vary", "Accept")
+
+	if resp.codec == nil {
+		resp.response.SetCode(http.StatusNotAcceptable)
+		resp.response.Header("Content-Type", "text/plain; charset=utf-8")
+		resp.response.SetContent([]byte(http.StatusText(http.StatusNotAcceptable)))
+
+		resp.response.Flush()
+		return nil
+	}
+
+	resp.response.SetCode(resp.code)
+	resp.response.Header("Content-Type", resp.codec.ContentType())
+	resp.response.SetContent(resp.body)
+
+	resp.response.Flush()
+	return nil
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProblemResponse is a application/problem+json response defined in RFC 7807
+type ProblemResponse struct {
+	response Responsor
+	problem  *Problem
+}
+
+// Code return the problem status, 500 if the problem has no status
+func (resp *ProblemResponse) Code() int {
+	return resp.problem.StatusCode()
+}
+
+// NewProblemResponse create a ProblemResponse
+func NewProblemResponse(response Responsor, problem *Problem) *ProblemResponse {
+	return &ProblemResponse{
+		response: response,
+		problem:  problem,
+	}
+}
+
+// Problem return the problem object
+func (resp *ProblemResponse) Problem() *Problem {
+	return resp.problem
+}
+
+// Send create response
+func (resp *ProblemResponse) Send() error {
+	problem := *resp.problem
+	problem.Status = resp.problem.StatusCode()
+
+	res, err := json.Marshal(&problem)
+	if err != nil {
+		return fmt.Errorf("json encode failed: %v [%v]", err, resp.problem)
+	}
+
+	resp.response.SetCode(problem.Status)
+	resp.response.Header("Content-Type", "application/problem+json; charset=utf-8")
+	resp.response.SetContent(res)
+
+	resp.response.Flush()
+	return nil
+}
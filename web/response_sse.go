@@ -0,0 +1,228 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEEvent is a server-sent event
+type SSEEvent struct {
+	// ID is the event id, client will send it back in Last-Event-ID header when reconnecting
+	ID string
+	// Event is the event type, client uses "message" if it's empty
+	Event string
+	// Data is the event payload, string and []byte are sent as is, other values are encoded as json
+	Data interface{}
+	// Retry is the reconnection time client should use
+	Retry time.Duration
+}
+
+// SSEWriter writes server-sent events to client, it's safe for concurrent use
+type SSEWriter struct {
+	lock        sync.Mutex
+	w           io.Writer
+	ctx         context.Context
+	lastEventID string
+}
+
+// LastEventID return the Last-Event-ID sent by client when reconnecting, it's empty for new connections
+func (sse *SSEWriter) LastEventID() string {
+	return sse.lastEventID
+}
+
+// Context return the request context, it's cancelled when client disconnected
+func (sse *SSEWriter) Context() context.Context {
+	return sse.ctx
+}
+
+// Done return a channel which is closed when client disconnected
+func (sse *SSEWriter) Done() <-chan struct{} {
+	return sse.ctx.Done()
+}
+
+// Send write a event to client
+func (sse *SSEWriter) Send(event SSEEvent) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: " + sseSanitize(event.ID) + "\n")
+	}
+
+	if event.Event != "" {
+		buf.WriteString("event: " + sseSanitize(event.Event) + "\n")
+	}
+
+	if event.Retry > 0 {
+		buf.WriteString(fmt.Sprintf("retry: %d\n", event.Retry.Milliseconds()))
+	}
+
+	if event.Data != nil {
+		data, err := sseData(event.Data)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+
+	buf.WriteString("\n")
+	return sse.write(buf.Bytes())
+}
+
+// Data write a event only contains data to client
+func (sse *SSEWriter) Data(data interface{}) error {
+	return sse.Send(SSEEvent{Data: data})
+}
+
+// Comment write a comment line to client, it's ignored by client and usually used to keep the connection alive
+func (sse *SSEWriter) Comment(comment string) error {
+	return sse.write([]byte(": " + sseSanitize(comment) + "\n\n"))
+}
+
+func (sse *SSEWriter) write(data []byte) error {
+	sse.lock.Lock()
+	defer sse.lock.Unlock()
+
+	_, err := sse.w.Write(data)
+	return err
+}
+
+// sseSanitize remove line breaks which would break the event stream
+func sseSanitize(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// sseData convert event data to string
+func sseData(data interface{}) (string, error) {
+	switch d := data.(type) {
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	default:
+		res, err := json.Marshal(d)
+		if err != nil {
+			return "", fmt.Errorf("json encode failed: %v [%v]", err, d)
+		}
+
+		return string(res), nil
+	}
+}
+
+// SSEResponse is a server-sent events response
+type SSEResponse struct {
+	stream    *StreamResponse
+	request   Request
+	fn        func(sse *SSEWriter) error
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+// NewSSEResponse create a SSEResponse, fn sends events until it returns or the client disconnected
+func NewSSEResponse(response Responsor, request Request, fn func(sse *SSEWriter) error) *SSEResponse {
+	resp := &SSEResponse{
+		request:   request,
+		fn:        fn,
+		heartbeat: 15 * time.Second,
+	}
+
+	resp.stream = NewStreamResponse(response, request, http.StatusOK, resp.serve).
+		WithContentType("text/event-stream; charset=utf-8")
+
+	return resp
+}
+
+func (resp *SSEResponse) Code() int {
+	return resp.stream.Code()
+}
+
+// WithHeartbeat set the interval for sending heartbeat comments, 0 disables heartbeat
+func (resp *SSEResponse) WithHeartbeat(interval time.Duration) *SSEResponse {
+	resp.heartbeat = interval
+	return resp
+}
+
+// WithRetry set the reconnection time sent to client when the stream opened
+func (resp *SSEResponse) WithRetry(retry time.Duration) *SSEResponse {
+	resp.retry = retry
+	return resp
+}
+
+// OnSent register a callback which will be called after the event stream closed
+func (resp *SSEResponse) OnSent(fn func(resp Response, err error)) {
+	resp.stream.OnSent(func(_ Response, err error) {
+		fn(resp, err)
+	})
+}
+
+// Send open the event stream, it returns when fn returns or the client disconnected
+func (resp *SSEResponse) Send() error {
+	resp.stream.response.Header("Cache-Control", "no-cache")
+	resp.stream.response.Header("Connection", "keep-alive")
+	resp.stream.response.Header("X-Accel-Buffering", "no")
+
+	err := resp.stream.Send()
+	if err == context.Canceled {
+		return nil
+	}
+
+	return err
+}
+
+// serve write events to the stream
+func (resp *SSEResponse) serve(w io.Writer) error {
+	sse := &SSEWriter{
+		w:           w,
+		ctx:         resp.request.Context(),
+		lastEventID: resp.request.Header("Last-Event-ID"),
+	}
+
+	// send something to open the stream immediately
+	if resp.retry > 0 {
+		if err := sse.Send(SSEEvent{Retry: resp.retry}); err != nil {
+			return err
+		}
+	} else if err := sse.Comment("ok"); err != nil {
+		return err
+	}
+
+	if resp.heartbeat > 0 {
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		defer func() {
+			close(stop)
+			wg.Wait()
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(resp.heartbeat)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if err := sse.Comment("heartbeat"); err != nil {
+						return
+					}
+				case <-stop:
+					return
+				case <-sse.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return resp.fn(sse)
+}
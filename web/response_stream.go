@@ -0,0 +1,167 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StreamResponse is a response which writes body to client as a stream instead of buffering it
+type StreamResponse struct {
+	response      Responsor
+	request       Request
+	fn            func(w io.Writer) error
+	contentType   string
+	code          int
+	flushInterval time.Duration
+	written       int64
+	callbacks     []func(resp Response, err error)
+	// exceptionHandler create the response for a error returned before writing, a plain 500 response is sent if it's nil
+	exceptionHandler func(err error) Response
+}
+
+// NewStreamResponse create a StreamResponse, fn writes the response body to w
+// the response header is sent when fn writes for the first time, so fn can still fail with a error response before writing
+func NewStreamResponse(response Responsor, request Request, code int, fn func(w io.Writer) error) *StreamResponse {
+	return &StreamResponse{
+		response:  response,
+		request:   request,
+		fn:        fn,
+		code:      code,
+		callbacks: make([]func(resp Response, err error), 0),
+	}
+}
+
+// NewStreamReaderResponse create a StreamResponse which copies body from reader
+func NewStreamReaderResponse(response Responsor, request Request, code int, reader io.Reader) *StreamResponse {
+	return NewStreamResponse(response, request, code, func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+}
+
+// Code return the response code, after the response has been sent, it's the status code sent to client
+func (resp *StreamResponse) Code() int {
+	return resp.code
+}
+
+// WithCode set response code and return itself
+func (resp *StreamResponse) WithCode(code int) *StreamResponse {
+	resp.code = code
+	return resp
+}
+
+// WithContentType set the Content-Type header and return itself
+func (resp *StreamResponse) WithContentType(contentType string) *StreamResponse {
+	resp.contentType = contentType
+	return resp
+}
+
+// WithFlushInterval set the min interval between two flushes, 0 means flushing after every write
+func (resp *StreamResponse) WithFlushInterval(interval time.Duration) *StreamResponse {
+	resp.flushInterval = interval
+	return resp
+}
+
+// Written return the bytes of body have been written to client
+func (resp *StreamResponse) Written() int64 {
+	return resp.written
+}
+
+// OnSent register a callback which will be called after the stream finished
+func (resp *StreamResponse) OnSent(fn func(resp Response, err error)) {
+	resp.callbacks = append(resp.callbacks, fn)
+}
+
+// Send write the stream to client until fn returns or the client disconnected
+func (resp *StreamResponse) Send() (err error) {
+	defer func() {
+		for _, cb := range resp.callbacks {
+			cb(resp, err)
+		}
+	}()
+
+	if resp.contentType != "" {
+		resp.response.Header("Content-Type", resp.contentType)
+	}
+	resp.response.DelHeader("Content-Length")
+	resp.response.SetCode(resp.code)
+
+	writer := &streamWriter{
+		resp:     resp,
+		ctx:      resp.request.Context(),
+		interval: resp.flushInterval,
+	}
+	writer.flusher, _ = resp.response.Raw().(http.Flusher)
+
+	err = resp.fn(writer)
+	if err != nil && !writer.started && resp.exceptionHandler != nil {
+		resp.response.DelHeader("Content-Type")
+
+		failure := resp.exceptionHandler(err)
+		_ = failure.Send()
+		resp.code = failure.Code()
+
+		return err
+	}
+
+	if err != nil && !writer.started {
+		resp.code = http.StatusInternalServerError
+		resp.response.SetCode(resp.code)
+		resp.response.Header("Content-Type", "text/plain; charset=utf-8")
+		resp.response.SetContent([]byte(err.Error()))
+		resp.response.Flush()
+
+		return err
+	}
+
+	if !writer.started {
+		resp.response.FlushHeader()
+	}
+
+	writer.flush()
+	return err
+}
+
+// streamWriter is a writer which sends data to client and flushes periodically
+type streamWriter struct {
+	resp      *StreamResponse
+	ctx       context.Context
+	flusher   http.Flusher
+	interval  time.Duration
+	lastFlush time.Time
+	started   bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if !w.started {
+		w.started = true
+		w.resp.response.FlushHeader()
+	}
+
+	n, err := w.resp.response.Raw().Write(p)
+	w.resp.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	if w.interval <= 0 || time.Since(w.lastFlush) >= w.interval {
+		w.flush()
+	}
+
+	return n, nil
+}
+
+// flush send buffered data to client
+func (w *streamWriter) flush() {
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+
+	w.lastFlush = time.Now()
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+func TestResponsorHeaders(t *testing.T) {
+	testCases := []struct {
+		name   string
+		update func(resp Responsor)
+		expect []string
+	}{
+		{
+			name:   "headers set to writer directly are kept",
+			update: func(resp Responsor) {},
+			expect: []string{"raw"},
+		},
+		{
+			name:   "add",
+			update: func(resp Responsor) { resp.AddHeader("Link", "added") },
+			expect: []string{"raw", "added"},
+		},
+		{
+			name:   "set",
+			update: func(resp Responsor) { resp.SetHeader("Link", "set") },
+			expect: []string{"set"},
+		},
+		{
+			name:   "delete",
+			update: func(resp Responsor) { resp.DelHeader("Link") },
+			expect: nil,
+		},
+		{
+			name: "add after delete",
+			update: func(resp Responsor) {
+				resp.DelHeader("Link")
+				resp.AddHeader("Link", "a", "b")
+			},
+			expect: []string{"a", "b"},
+		},
+		{
+			name: "add after set",
+			update: func(resp Responsor) {
+				resp.SetHeader("Link", "a")
+				resp.AddHeader("Link", "b")
+			},
+			expect: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		router := NewRouter(container.New(), DefaultConfig())
+		router.Group("", func(router *Router) {
+			router.Get("/", func(ctx Context) Response {
+				tc.update(ctx.Response())
+				return ctx.HTML("ok")
+			})
+		}, func(handler Handler) Handler {
+			return func(ctx Context) Response {
+				ctx.Response().Raw().Header().Set("Link", "raw")
+				return handler(ctx)
+			}
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if values := w.Header()["Link"]; !stringsEqual(values, tc.expect) {
+			t.Errorf("%s: expect headers %v, got %v", tc.name, tc.expect, values)
+		}
+	}
+}
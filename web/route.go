@@ -3,9 +3,14 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
+// UnlimitedBodySize is the max body size of routes which don't limit request body size
+const UnlimitedBodySize int64 = -1
+
 // SimpleRoute is a route for request
 type SimpleRoute struct {
 	hosts        []string
@@ -13,6 +18,9 @@ type SimpleRoute struct {
 	methods      []string
 	contentTypes []string
 	handler      interface{}
+	maxBodySize  int64
+	timeout      time.Duration
+	name         string
 
 	parsedPaths map[ParsedPathType][]ParsedPath
 	decorators  []HandlerDecorator
@@ -30,6 +38,37 @@ func (route *SimpleRoute) Decorators() []HandlerDecorator {
 	return route.decorators
 }
 
+// WithName set a name for current route, named routes can be used for generating urls
+func (route *SimpleRoute) WithName(name string) {
+	route.name = name
+}
+
+func (route *SimpleRoute) Name() string {
+	return route.name
+}
+
+// WithMaxBodySize set the max request body size for current route, which overrides Config.MaxBodySize
+// 0 means using Config.MaxBodySize, UnlimitedBodySize(or any negative size) means no limit even if Config.MaxBodySize is set
+func (route *SimpleRoute) WithMaxBodySize(size int64) {
+	route.maxBodySize = size
+}
+
+func (route *SimpleRoute) MaxBodySize() int64 {
+	return route.maxBodySize
+}
+
+// WithTimeout set the timeout for current route, the handler gets a deadline context from Context.Context
+// and ErrHandlerTimeout is handled by exception pipeline when it's exceeded
+// the handler runs in another goroutine, header maps returned by Responsor.Headers and Raw().Header() are copies
+// which are applied to the response when the handler returns in time
+func (route *SimpleRoute) WithTimeout(timeout time.Duration) {
+	route.timeout = timeout
+}
+
+func (route *SimpleRoute) Timeout() time.Duration {
+	return route.timeout
+}
+
 func (route *SimpleRoute) Hosts() []string {
 	return route.hosts
 }
@@ -218,6 +257,31 @@ func (route *SimpleRoute) MatchPath(segments []string) (bool, map[string]string)
 	return true, pathVars
 }
 
+// URL generate a url path for current route, placeholders are replaced by vars
+// placeholder names are matched case-insensitively, since route paths are lowercased
+func (route *SimpleRoute) URL(vars map[string]string) (string, error) {
+	lowerVars := make(map[string]string, len(vars))
+	for k, v := range vars {
+		lowerVars[strings.ToLower(k)] = v
+	}
+
+	segments := make([]string, len(route.parsedPaths[ParsedPathPlain])+len(route.parsedPaths[ParsedPathPlaceholder]))
+	for _, segment := range route.parsedPaths[ParsedPathPlain] {
+		segments[segment.Index] = segment.Segment
+	}
+
+	for _, segment := range route.parsedPaths[ParsedPathPlaceholder] {
+		val, ok := lowerVars[segment.Segment]
+		if !ok {
+			return "", fmt.Errorf("missing path variable %s for route %s", segment.Segment, route.path)
+		}
+
+		segments[segment.Index] = url.PathEscape(val)
+	}
+
+	return "/" + strings.Join(segments, "/"), nil
+}
+
 func (route *SimpleRoute) Handle() interface{} {
 	return route.handler
 }
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/mylxsw/container"
+	"github.com/pkg/errors"
 )
 
 // Router is route manager
@@ -22,6 +23,10 @@ type Router struct {
 	exceptionHandler     ExceptionHandler
 	routeNotFoundHandler RouteNotFoundHandler
 	logger               Log
+	negotiation          bool
+	problemDetails       bool
+	panicReporters       []PanicReporter
+	debugPage            bool
 }
 
 // ExceptionHandler is a function interface for exception handler
@@ -37,6 +42,9 @@ func NewRouter(cc container.Container, conf *Config, decors ...HandlerDecorator)
 		return structDecoder{}
 	})
 	ccc.MustSingleton(func() *Config { return conf })
+	ccc.MustSingleton(DefaultCodecRegistry)
+	ccc.MustSingleton(NewErrorMapping)
+	ccc.MustSingleton(DefaultResultConverters)
 
 	return createRouter(ccc, conf, decors...)
 }
@@ -63,6 +71,40 @@ func (router *Router) WithRouteNotFoundHandler(fn RouteNotFoundHandler) *Router
 	return router
 }
 
+// Codecs return the codec registry used for decoding request body and encoding response
+func (router *Router) Codecs() *CodecRegistry {
+	return router.cc.MustGet((*CodecRegistry)(nil)).(*CodecRegistry)
+}
+
+// Errors return the error mapping registry, which is consulted before the exception handler
+//
+//	router.Errors().
+//	    Map(sql.ErrNoRows, http.StatusNotFound).
+//	    Map(context.DeadlineExceeded, http.StatusGatewayTimeout).
+//	    MapType((*ConflictError)(nil), http.StatusConflict)
+func (router *Router) Errors() *ErrorMapping {
+	return router.cc.MustGet((*ErrorMapping)(nil)).(*ErrorMapping)
+}
+
+// Converters return the result converters registry, which turns handler return values into responses
+func (router *Router) Converters() *ResultConverters {
+	return router.cc.MustGet((*ResultConverters)(nil)).(*ResultConverters)
+}
+
+// WithContentNegotiation enable content negotiation for handler return values
+// when enabled, structs returned by handlers are encoded by the codec chosen from Accept header instead of JSON
+func (router *Router) WithContentNegotiation() *Router {
+	router.negotiation = true
+	return router
+}
+
+// WithProblemDetails make the default exception handling render errors as RFC 7807 problem+json
+// it only takes effect when there is no custom exception handler
+func (router *Router) WithProblemDetails() *Router {
+	router.problemDetails = true
+	return router
+}
+
 // WithLogger set a logger for router
 func (router *Router) WithLogger(logger Log) *Router {
 	router.logger = logger
@@ -105,6 +147,9 @@ func (router *Router) Group(prefix string, f func(router *Router), decors ...Han
 		route.WithPath(prefix + "/" + strings.TrimLeft(r.Path(), "/"))
 		route.WithHandler(r.Handle())
 		route.WithDecorators(r.Decorators()...)
+		route.WithMaxBodySize(r.MaxBodySize())
+		route.WithTimeout(r.Timeout())
+		route.WithName(r.Name())
 		router.AddRoute(route)
 	}
 }
@@ -181,6 +226,26 @@ func (router *Router) Routes() []Route {
 	return router.routes
 }
 
+// RouteURL generate a url path for the route with name, pairs are path variables in form of key1, value1, key2, value2...
+func (router *Router) RouteURL(name string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("path variables for route %s must be key-value pairs", name)
+	}
+
+	vars := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		vars[fmt.Sprintf("%v", pairs[i])] = fmt.Sprintf("%v", pairs[i+1])
+	}
+
+	for _, r := range router.Routes() {
+		if r.Name() == name {
+			return r.URL(vars)
+		}
+	}
+
+	return "", fmt.Errorf("route %s not found", name)
+}
+
 // Match return whether current SimpleRoute is matched with registered routes
 func (router *Router) Match(current RealRoute) (Route, map[string]string) {
 	router.lock.RLock()
@@ -209,13 +274,49 @@ func (router *Router) handleRouteNotFound(wtx Context, route RealRoute) {
 }
 
 func (router *Router) handleException(wtx Context, err error) Response {
+	if rule, matched, ok := router.Errors().lookup(err); ok {
+		if rule.builder != nil {
+			if resp := rule.builder(wtx, matched); resp != nil {
+				return resp
+			}
+		} else {
+			err = statusError{err: err, code: rule.code}
+		}
+	}
+
 	if router.exceptionHandler == nil {
-		return NewErrorResponse(wtx.Response(), err.Error(), http.StatusInternalServerError)
+		return router.defaultExceptionResponse(wtx, err)
 	}
 
 	return router.exceptionHandler(wtx, err)
 }
 
+// defaultExceptionResponse create the response for err when there is no custom exception handler
+// the status code comes from the Error in the error chain(500 if absent), JSONAble errors are rendered as json,
+// other errors are rendered as plain text
+func (router *Router) defaultExceptionResponse(wtx Context, err error) Response {
+	if router.problemDetails {
+		problem := *ProblemFromError(err)
+		if problem.Instance == "" {
+			problem.Instance = wtx.Request().Raw().URL.Path
+		}
+
+		return wtx.Problem(&problem)
+	}
+
+	var httpErr Error
+	if !errors.As(err, &httpErr) {
+		return NewErrorResponse(wtx.Response(), err.Error(), http.StatusInternalServerError)
+	}
+
+	var jsonAble JSONAble
+	if errors.As(err, &jsonAble) {
+		return wtx.JSONWithCode(jsonAble.ToJSON(), httpErr.StatusCode())
+	}
+
+	return NewErrorResponse(wtx.Response(), httpErr.Error(), httpErr.StatusCode())
+}
+
 func (router *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	realRoute := NewRealRoute(request)
 	matchedRoute, pathVars := router.Match(realRoute)
@@ -224,13 +325,57 @@ func (router *Router) ServeHTTP(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
+	if limit := router.maxBodySize(matchedRoute); limit > 0 && request.Body != nil {
+		request.Body = newLimitedBody(writer, request.Body, limit)
+	}
+
 	ctx := NewWebContext(router, pathVars, writer, request)
+	ctx.(*webContext).route = matchedRoute
 	router.handle(ctx, matchedRoute)
 }
 
+// maxBodySize return the max request body size for route, 0 means no limit
+func (router *Router) maxBodySize(route Route) int64 {
+	if size := route.MaxBodySize(); size > 0 {
+		return size
+	} else if size < 0 {
+		return 0
+	}
+
+	if router.conf != nil {
+		return router.conf.MaxBodySize
+	}
+
+	return 0
+}
+
 func (router *Router) handle(ctx Context, matchedRoute Route) {
+	defer func() {
+		if req, ok := ctx.Request().(*httpRequest); ok {
+			req.cleanup()
+		}
+	}()
 
-	handler := func(ctx Context) (resp Response) {
+	handler := router.routeHandler(matchedRoute)
+
+	decors := matchedRoute.Decorators()
+	for i := range decors {
+		d := decors[i]
+		handler = d(handler)
+	}
+
+	if timeout := matchedRoute.Timeout(); timeout > 0 {
+		handler = withTimeout(handler, timeout)
+	}
+
+	if err := router.recoverHandler(handler)(ctx).Send(); err != nil && router.logger != nil {
+		router.logger.Errorf("send response failed: %v", err)
+	}
+}
+
+// routeHandler create the handler calls the route handler without decorators
+func (router *Router) routeHandler(matchedRoute Route) Handler {
+	return func(ctx Context) (resp Response) {
 		ctxCB := func() Context { return ctx }
 		reqCB := func() Request { return ctx.Request() }
 		respCB := func() Responsor { return ctx.Response() }
@@ -238,14 +383,13 @@ func (router *Router) handle(ctx Context, matchedRoute Route) {
 
 		provider, _ := router.cc.Provider(ctxCB, reqCB, respCB, matchedRouteCB)
 
+		if limit := router.maxBodySize(matchedRoute); limit > 0 && ctx.Request().Raw().ContentLength > limit {
+			return router.handleException(ctx, ErrRequestBodyTooLarge)
+		}
+
 		defer func() {
 			if err := recover(); err != nil {
-				switch err.(type) {
-				case error:
-					resp = router.handleException(ctx, err.(error))
-				default:
-					resp = router.handleException(ctx, fmt.Errorf("%v", err))
-				}
+				resp = router.handlePanic(ctx, err)
 			}
 		}()
 
@@ -256,51 +400,126 @@ func (router *Router) handle(ctx Context, matchedRoute Route) {
 
 		return router.parseResponse(ctx, results)
 	}
+}
 
-	decors := matchedRoute.Decorators()
-	for i := range decors {
-		d := decors[i]
-		handler = d(handler)
-	}
+// recoverHandler wrap the full decorator chain, so panics in decorators are recovered too
+func (router *Router) recoverHandler(handler Handler) Handler {
+	return func(ctx Context) (resp Response) {
+		defer func() {
+			if err := recover(); err != nil {
+				resp = router.handlePanic(ctx, err)
+			}
+		}()
 
-	if err := handler(ctx).Send(); err != nil && router.logger != nil {
-		router.logger.Errorf("send response failed: %v", err)
+		return handler(ctx)
 	}
 }
 
+// parseResponse convert handler return values to Response, following return conventions are supported
+//
+//	T              value converted by result converters
+//	error          nil for the response set by handler, otherwise handled by exception pipeline
+//	(T, error)     the error is handled by exception pipeline if not nil
+//	(T, int, error) same as (T, error), the int is used as response status code
 func (router *Router) parseResponse(ctx Context, results []interface{}) Response {
 	if len(results) == 0 {
 		return ctx.Nil()
 	}
 
-	if len(results) > 1 {
-		if err, ok := results[1].(error); ok {
-			if err != nil {
-				return router.exceptionHandler(ctx, err)
-			}
+	// the last error returned takes precedence over values
+	for i := len(results) - 1; i >= 0; i-- {
+		if err, ok := results[i].(error); ok && err != nil {
+			return router.handleException(ctx, err)
 		}
 	}
 
-	switch results[0].(type) {
-	case Response:
-		return results[0].(Response)
-	case string:
-		return ctx.HTML(results[0].(string))
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-		return ctx.HTML(fmt.Sprintf("%d", results[0]))
-	case float32, float64:
-		return ctx.HTML(fmt.Sprintf("%f", results[0]))
-	case error:
-		if results[0] == nil {
-			return ctx.HTML("")
+	// a nil error sends what the handler has set to response
+	if len(results) == 1 && results[0] == nil {
+		if ctx.Response().GetCode() == 0 {
+			ctx.Response().SetCode(http.StatusOK)
 		}
 
-		panic(results[0])
-	default:
-		if jsonAble, ok := results[0].(JSONAble); ok {
-			return ctx.JSON(jsonAble.ToJSON())
+		return ctx.Plain()
+	}
+
+	var resp Response
+	if results[0] == nil {
+		resp = ctx.HTML("")
+	} else {
+		resp = router.convertResult(ctx, results[0])
+	}
+
+	if len(results) == 3 {
+		if code, ok := results[1].(int); ok && code > 0 {
+			if err := withResponseCode(ctx, resp, code); err != nil {
+				return router.handleException(ctx, err)
+			}
 		}
+	}
+
+	return resp
+}
+
+// convertResult convert a handler return value to Response, Response values are used as is without consulting converters
+func (router *Router) convertResult(ctx Context, result interface{}) Response {
+	if resp, ok := result.(Response); ok {
+		return resp
+	}
 
-		return ctx.JSON(results[0])
+	if resp, ok := router.Converters().Convert(ctx, result); ok {
+		return resp
 	}
+
+	res := result
+	if jsonAble, ok := res.(JSONAble); ok {
+		res = jsonAble.ToJSON()
+	}
+
+	if router.negotiation {
+		return ctx.Negotiate(res)
+	}
+
+	return ctx.JSON(res)
+}
+
+// codeResponse is a custom response which status code can be changed
+type codeResponse interface {
+	WithCode(code int) Response
+}
+
+// withResponseCode override the status code of resp
+// responses decide status code by themselves(eg. FileResponse, SSEResponse) can not be overridden, a error is returned for them
+func withResponseCode(ctx Context, resp Response, code int) error {
+	switch r := resp.(type) {
+	case *JSONResponse:
+		r.WithCode(code)
+	case *HTMLResponse:
+		r.WithCode(code)
+	case *YAMLResponse:
+		r.WithCode(code)
+	case *CodecResponse:
+		r.WithCode(code)
+	case *NegotiateResponse:
+		r.WithCode(code)
+	case *ErrorResponse:
+		r.WithCode(code)
+	case *RedirectResponse:
+		r.WithCode(code)
+	case *StreamResponse:
+		r.WithCode(code)
+	case *ViewResponse:
+		r.WithCode(code)
+	case *ProblemResponse:
+		problem := *r.problem
+		problem.Status = code
+		r.problem = &problem
+	case *RawResponse, *NilResponse:
+		ctx.Response().SetCode(code)
+	case codeResponse:
+		r.WithCode(code)
+	default:
+		return fmt.Errorf("status code %d can not be applied to %T", code, resp)
+	}
+
+	return nil
 }
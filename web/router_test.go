@@ -0,0 +1,153 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mylxsw/container"
+)
+
+func TestHandlerReturnValues(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler interface{}
+		code    int
+		body    string
+		// customCode and customBody are expected when a exception handler is set, they are same as code and body if empty
+		customCode int
+		customBody string
+		cookie     string
+	}{
+		{
+			name:    "T",
+			handler: func() M { return M{"name": "router"} },
+			code:    http.StatusOK,
+			body:    `{"name":"router"}`,
+		},
+		{
+			name:       "error",
+			handler:    func() error { return WrapPlainError(errors.New("conflict"), http.StatusConflict) },
+			code:       http.StatusConflict,
+			body:       "conflict",
+			customCode: http.StatusTeapot,
+			customBody: `{"error":"conflict"}`,
+		},
+		{
+			name: "nil error",
+			handler: func(ctx Context) error {
+				ctx.Response().SetCode(http.StatusCreated)
+				ctx.Response().Cookie(NewCookie("session", "abc", 0))
+				return nil
+			},
+			code:   http.StatusCreated,
+			body:   "",
+			cookie: "session",
+		},
+		{
+			name:    "(T, error) with value",
+			handler: func() (M, error) { return M{"name": "router"}, nil },
+			code:    http.StatusOK,
+			body:    `{"name":"router"}`,
+		},
+		{
+			name:       "(T, error) with error",
+			handler:    func() (M, error) { return nil, errors.New("failed") },
+			code:       http.StatusInternalServerError,
+			body:       "failed",
+			customCode: http.StatusTeapot,
+			customBody: `{"error":"failed"}`,
+		},
+		{
+			name: "(T, int, error) with response",
+			handler: func(ctx Context) (Response, int, error) {
+				return ctx.JSON(M{"id": 1}), http.StatusCreated, nil
+			},
+			code: http.StatusCreated,
+			body: `{"id":1}`,
+		},
+		{
+			name:    "(T, int, error) with value",
+			handler: func() (M, int, error) { return M{"id": 1}, http.StatusAccepted, nil },
+			code:    http.StatusAccepted,
+			body:    `{"id":1}`,
+		},
+		{
+			name:    "(T, int, error) with nil value",
+			handler: func() (Response, int, error) { return nil, http.StatusAccepted, nil },
+			code:    http.StatusAccepted,
+			body:    "",
+		},
+		{
+			name: "(T, int, error) with problem",
+			handler: func(ctx Context) (Response, int, error) {
+				return ctx.Problem(&Problem{Title: "gone"}), http.StatusGone, nil
+			},
+			code: http.StatusGone,
+			body: `{"status":410,"title":"gone"}`,
+		},
+		{
+			name: "(T, int, error) with raw response",
+			handler: func(ctx Context) (Response, int, error) {
+				ctx.Response().SetContent([]byte("raw"))
+				return ctx.Plain(), http.StatusAccepted, nil
+			},
+			code: http.StatusAccepted,
+			body: "raw",
+		},
+		{
+			name: "(T, int, error) with response decides code by itself",
+			handler: func(ctx Context) (Response, int, error) {
+				return ctx.SSE(func(w *SSEWriter) error { return nil }), http.StatusAccepted, nil
+			},
+			code:       http.StatusInternalServerError,
+			body:       "status code 202 can not be applied to *web.SSEResponse",
+			customCode: http.StatusTeapot,
+			customBody: `{"error":"status code 202 can not be applied to *web.SSEResponse"}`,
+		},
+		{
+			name: "(T, int, error) with error",
+			handler: func() (Response, int, error) {
+				return nil, http.StatusCreated, WrapJSONError(errors.New("invalid"), http.StatusBadRequest)
+			},
+			code:       http.StatusBadRequest,
+			body:       `{"error":"invalid"}`,
+			customCode: http.StatusTeapot,
+			customBody: `{"error":"invalid"}`,
+		},
+	}
+
+	for _, withHandler := range []bool{false, true} {
+		for _, tc := range testCases {
+			router := NewRouter(container.New(), DefaultConfig())
+			if withHandler {
+				router.WithExceptionHandler(func(ctx Context, err error) Response {
+					return ctx.JSONWithCode(M{"error": err.Error()}, http.StatusTeapot)
+				})
+			}
+
+			router.Get("/", tc.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			code, body := tc.code, tc.body
+			if withHandler && tc.customCode != 0 {
+				code, body = tc.customCode, tc.customBody
+			}
+
+			if w.Code != code {
+				t.Errorf("%s (exception handler: %v): expect code %d, got %d", tc.name, withHandler, code, w.Code)
+			}
+
+			if w.Body.String() != body {
+				t.Errorf("%s (exception handler: %v): expect body %q, got %q", tc.name, withHandler, body, w.Body.String())
+			}
+
+			if tc.cookie != "" && len(w.Result().Cookies()) == 0 {
+				t.Errorf("%s (exception handler: %v): expect cookie %s", tc.name, withHandler, tc.cookie)
+			}
+		}
+	}
+}
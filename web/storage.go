@@ -0,0 +1,312 @@
+package web
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFileNotFound is the error when a file not exist in storage
+var ErrFileNotFound = errors.New("file not found")
+
+// Storage is a interface for file storage backends
+type Storage interface {
+	// Store save the content from reader to path, returns the path in storage which can be used with Open, Exists and Delete
+	Store(path string, reader io.Reader) (string, error)
+	// Open open a file in storage for reading
+	Open(path string) (io.ReadCloser, error)
+	// Exists return whether the path exists in storage
+	Exists(path string) bool
+	// Delete remove a file from storage
+	Delete(path string) error
+}
+
+// LocalStorage is a storage backend which saves files to local disk
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage create a new LocalStorage with root directory
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+// Store save the content to a file under root directory, the file is written atomically
+// files are created with mode 0644(restricted by umask) like os.Create does
+func (s *LocalStorage) Store(p string, reader io.Reader) (string, error) {
+	dest := s.realPath(p)
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return "", errors.Wrap(err, "create directory failed")
+	}
+
+	tempFile, err := createTempFile(filepath.Dir(dest), ".upload-", 0644)
+	if err != nil {
+		return "", errors.Wrap(err, "create temporary file failed")
+	}
+
+	if _, err := io.Copy(tempFile, reader); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+		return "", errors.Wrap(err, "write file failed")
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempFile.Name())
+		return "", errors.Wrap(err, "write file failed")
+	}
+
+	if err := os.Rename(tempFile.Name(), dest); err != nil {
+		_ = os.Remove(tempFile.Name())
+		return "", errors.Wrap(err, "rename file failed")
+	}
+
+	return p, nil
+}
+
+// Open open a file under root directory
+func (s *LocalStorage) Open(p string) (io.ReadCloser, error) {
+	f, err := os.Open(s.realPath(p))
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+
+	return f, err
+}
+
+// Exists return whether the file exists under root directory
+func (s *LocalStorage) Exists(p string) bool {
+	_, err := os.Stat(s.realPath(p))
+	return err == nil
+}
+
+// Delete remove a file under root directory
+func (s *LocalStorage) Delete(p string) error {
+	return os.Remove(s.realPath(p))
+}
+
+// realPath return the path on disk, paths are always kept under root directory
+func (s *LocalStorage) realPath(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// createTempFile create a new file with a random name in dir, unlike ioutil.TempFile which always uses mode 0600,
+// the file is created with perm(restricted by umask)
+func createTempFile(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for i := 0; i < 100; i++ {
+		suffix := make([]byte, 8)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, prefix+hex.EncodeToString(suffix)), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return f, err
+	}
+
+	return nil, errors.New("too many temporary files")
+}
+
+// MemoryStorage is a storage backend which keeps files in memory
+type MemoryStorage struct {
+	lock  sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage create a new MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+// Store save the content to memory
+func (s *MemoryStorage) Store(p string, reader io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.files[p] = data
+	return p, nil
+}
+
+// Open open a file in memory
+func (s *MemoryStorage) Open(p string) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, ok := s.files[p]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Exists return whether the file exists in memory
+func (s *MemoryStorage) Exists(p string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.files[p]
+	return ok
+}
+
+// Delete remove a file from memory
+func (s *MemoryStorage) Delete(p string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.files[p]; !ok {
+		return ErrFileNotFound
+	}
+
+	delete(s.files, p)
+	return nil
+}
+
+// ContentAddressedStorage is a storage backend which names files by sha256 checksum of their content
+// files with same content are only saved once
+type ContentAddressedStorage struct {
+	backend Storage
+	tempDir string
+}
+
+// NewContentAddressedStorage create a new ContentAddressedStorage on top of another storage backend
+// tempDir is used for buffering content while computing checksum
+func NewContentAddressedStorage(backend Storage, tempDir string) *ContentAddressedStorage {
+	return &ContentAddressedStorage{backend: backend, tempDir: tempDir}
+}
+
+// Store save the content with a path like ab/cd/abcd...ef.ext, only the extension of p is used
+func (s *ContentAddressedStorage) Store(p string, reader io.Reader) (string, error) {
+	tempFile, err := ioutil.TempFile(s.tempDir, "cas-")
+	if err != nil {
+		return "", errors.Wrap(err, "create temporary file failed")
+	}
+
+	defer func() {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tempFile, hash), reader); err != nil {
+		return "", errors.Wrap(err, "write temporary file failed")
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	casPath := path.Join(sum[0:2], sum[2:4], sum+path.Ext(p))
+	if s.backend.Exists(casPath) {
+		return casPath, nil
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if _, err := s.backend.Store(casPath, tempFile); err != nil {
+		return "", err
+	}
+
+	return casPath, nil
+}
+
+// Open open a file in backend storage
+func (s *ContentAddressedStorage) Open(p string) (io.ReadCloser, error) {
+	return s.backend.Open(p)
+}
+
+// Exists return whether the file exists in backend storage
+func (s *ContentAddressedStorage) Exists(p string) bool {
+	return s.backend.Exists(p)
+}
+
+// Delete remove a file from backend storage
+func (s *ContentAddressedStorage) Delete(p string) error {
+	return s.backend.Delete(p)
+}
+
+// moveFile rename a file, if failed(eg. across filesystems), copy it to destination and remove the source
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		_ = destFile.Close()
+		_ = os.Remove(dest)
+		return err
+	}
+
+	if err := destFile.Close(); err != nil {
+		_ = os.Remove(dest)
+		return err
+	}
+
+	_ = srcFile.Close()
+	return os.Remove(src)
+}
+
+// preferredExtensions is the extensions used for common mime types
+var preferredExtensions = map[string]string{
+	"image/jpeg":      "jpg",
+	"image/png":       "png",
+	"image/gif":       "gif",
+	"image/webp":      "webp",
+	"image/bmp":       "bmp",
+	"application/pdf": "pdf",
+	"application/zip": "zip",
+	"text/plain":      "txt",
+	"text/html":       "html",
+	"text/xml":        "xml",
+	"audio/mpeg":      "mp3",
+	"video/mp4":       "mp4",
+}
+
+// mimeExtension return a extension without leading dot for mime type
+func mimeExtension(mimeType string) string {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		return ""
+	}
+
+	if ext, ok := preferredExtensions[mimeType]; ok {
+		return ext
+	}
+
+	exts, err := mime.ExtensionsByType(mimeType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+
+	return strings.TrimPrefix(exts[0], ".")
+}
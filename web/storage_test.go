@@ -0,0 +1,84 @@
+package web
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root, err := ioutil.TempDir("", "storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// the mode of files created by os with 0644 under current umask
+	probe, err := os.OpenFile(filepath.Join(root, "probe"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = probe.Close()
+	probeInfo, _ := os.Stat(probe.Name())
+
+	storage := NewLocalStorage(root)
+
+	testCases := []struct {
+		name string
+		path string
+		disk string
+	}{
+		{name: "file", path: "a.txt", disk: "a.txt"},
+		{name: "nested file", path: "images/2020/b.png", disk: "images/2020/b.png"},
+		{name: "parent directories are kept under root", path: "../../c.txt", disk: "c.txt"},
+		{name: "absolute path is under root", path: filepath.Join(root, "d.txt"), disk: filepath.Join(strings.TrimPrefix(root, "/"), "d.txt")},
+	}
+
+	for _, tc := range testCases {
+		stored, err := storage.Store(tc.path, strings.NewReader(tc.name))
+		if err != nil {
+			t.Errorf("%s: store failed: %v", tc.name, err)
+			continue
+		}
+
+		if stored != tc.path {
+			t.Errorf("%s: expect stored path %s, got %s", tc.name, tc.path, stored)
+		}
+
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(tc.disk)))
+		if err != nil {
+			t.Errorf("%s: expect file on disk: %v", tc.name, err)
+			continue
+		}
+
+		if info.Mode().Perm() != probeInfo.Mode().Perm() {
+			t.Errorf("%s: expect mode %v, got %v", tc.name, probeInfo.Mode().Perm(), info.Mode().Perm())
+		}
+
+		if !storage.Exists(stored) {
+			t.Errorf("%s: expect stored path exists", tc.name)
+		}
+
+		reader, err := storage.Open(stored)
+		if err != nil {
+			t.Errorf("%s: open failed: %v", tc.name, err)
+			continue
+		}
+
+		content, _ := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if string(content) != tc.name {
+			t.Errorf("%s: expect content %q, got %q", tc.name, tc.name, content)
+		}
+
+		if err := storage.Delete(stored); err != nil || storage.Exists(stored) {
+			t.Errorf("%s: expect stored path deleted, got %v", tc.name, err)
+		}
+	}
+
+	if _, err := storage.Open("missing.txt"); err != ErrFileNotFound {
+		t.Errorf("expect ErrFileNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CSRFTokenKey is the key used for storing csrf token in request, it's read by csrf_token view helper
+const CSRFTokenKey = "csrf_token"
+
+// contentTemplate is the template name for the page, layouts render it by {{ template "content" . }}
+const contentTemplate = "content"
+
+// ViewOptions is the options for ViewEngine
+type ViewOptions struct {
+	// Dir is the root directory of templates
+	Dir string
+	// Extension is the extension of template files
+	Extension string
+	// LayoutDir is the directory of layouts relative to Dir, layouts are referenced as layouts/name
+	LayoutDir string
+	// PartialDir is the directory of partials relative to Dir, partials are referenced as {{ template "partials/name" . }}
+	PartialDir string
+	// DefaultLayout is the layout used when response doesn't specify one, empty means no layout
+	DefaultLayout string
+	// AssetPrefix is the url prefix used by asset helper
+	AssetPrefix string
+	// Development reloads templates from disk on every render instead of using precompiled ones
+	Development bool
+	// Funcs is additional template functions
+	Funcs template.FuncMap
+}
+
+// DefaultViewOptions create a default view options with templates in dir
+func DefaultViewOptions(dir string) ViewOptions {
+	return ViewOptions{
+		Dir:         dir,
+		Extension:   ".html",
+		LayoutDir:   "layouts",
+		PartialDir:  "partials",
+		AssetPrefix: "/assets/",
+	}
+}
+
+// ViewEngine is a template engine built on html/template which supports layouts and partials
+// every page is compiled together with all layouts and partials, the page itself is named "content"
+type ViewEngine struct {
+	lock      sync.RWMutex
+	opts      ViewOptions
+	router    *Router
+	templates map[string]*compiledView
+}
+
+// compiledView is a precompiled page, copies of it with request scoped helpers bound are reused across requests
+type compiledView struct {
+	tpl       *template.Template
+	instances sync.Pool
+}
+
+// viewInstance is a copy of a compiled page, its request scoped helpers read the context of current rendering
+type viewInstance struct {
+	tpl *template.Template
+	ctx Context
+}
+
+func (instance *viewInstance) context() Context {
+	return instance.ctx
+}
+
+// NewViewEngine create a new ViewEngine
+func NewViewEngine(opts ViewOptions) *ViewEngine {
+	if opts.Extension == "" {
+		opts.Extension = ".html"
+	}
+
+	return &ViewEngine{
+		opts:      opts,
+		templates: make(map[string]*compiledView),
+	}
+}
+
+// WithViews set a view engine for router, templates are precompiled if not in development mode
+func (router *Router) WithViews(engine *ViewEngine) *Router {
+	engine.router = router
+	if !engine.opts.Development {
+		if err := engine.Load(); err != nil {
+			panic(fmt.Sprintf("load views failed: %v", err))
+		}
+	}
+
+	router.cc.MustSingletonOverride(func() *ViewEngine { return engine })
+	return router
+}
+
+// Load compile all pages in template directory
+func (engine *ViewEngine) Load() error {
+	shared, pages, err := engine.scan()
+	if err != nil {
+		return err
+	}
+
+	templates := make(map[string]*compiledView)
+	for name, path := range pages {
+		tpl, err := engine.compile(shared, path)
+		if err != nil {
+			return err
+		}
+
+		templates[name] = &compiledView{tpl: tpl}
+	}
+
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	engine.templates = templates
+	return nil
+}
+
+// Render render a page with layout to w, layout can be empty for rendering page only
+func (engine *ViewEngine) Render(w io.Writer, ctx Context, name string, layout string, data interface{}) error {
+	instance, release, err := engine.lookup(name)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	instance.ctx = ctx
+
+	entry := contentTemplate
+	if layout != "" {
+		entry = filepath.ToSlash(filepath.Join(engine.opts.LayoutDir, layout))
+	}
+
+	return instance.tpl.ExecuteTemplate(w, entry, data)
+}
+
+// lookup return a instance of the page with request scoped helpers bound, release must be called after rendering
+// the page is compiled from disk in development mode, otherwise a idle copy of the precompiled page is reused
+func (engine *ViewEngine) lookup(name string) (*viewInstance, func(), error) {
+	if engine.opts.Development {
+		shared, pages, err := engine.scan()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		path, ok := pages[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("view %s not found", name)
+		}
+
+		tpl, err := engine.compile(shared, path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		instance := &viewInstance{}
+		instance.tpl = tpl.Funcs(engine.requestFuncs(instance.context))
+
+		return instance, func() {}, nil
+	}
+
+	engine.lock.RLock()
+	view, ok := engine.templates[name]
+	engine.lock.RUnlock()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("view %s not found", name)
+	}
+
+	instance, ok := view.instances.Get().(*viewInstance)
+	if !ok {
+		tpl, err := view.tpl.Clone()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		instance = &viewInstance{}
+		instance.tpl = tpl.Funcs(engine.requestFuncs(instance.context))
+	}
+
+	return instance, func() {
+		instance.ctx = nil
+		view.instances.Put(instance)
+	}, nil
+}
+
+// scan find all template files, returns shared(layouts and partials) and pages keyed by name
+func (engine *ViewEngine) scan() (map[string]string, map[string]string, error) {
+	shared := make(map[string]string)
+	pages := make(map[string]string)
+
+	err := filepath.Walk(engine.opts.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, engine.opts.Extension) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(engine.opts.Dir, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(strings.TrimSuffix(rel, engine.opts.Extension))
+		if engine.inDir(name, engine.opts.LayoutDir) || engine.inDir(name, engine.opts.PartialDir) {
+			shared[name] = path
+		} else {
+			pages[name] = path
+		}
+
+		return nil
+	})
+
+	return shared, pages, err
+}
+
+// inDir return whether the template name is in dir
+func (engine *ViewEngine) inDir(name string, dir string) bool {
+	return dir != "" && strings.HasPrefix(name, filepath.ToSlash(dir)+"/")
+}
+
+// compile parse all shared templates and the page into a template set
+func (engine *ViewEngine) compile(shared map[string]string, page string) (*template.Template, error) {
+	tpl := template.New(contentTemplate).Funcs(engine.funcs())
+	for name, path := range shared {
+		if err := parseTemplateFile(tpl.New(name), path); err != nil {
+			return nil, err
+		}
+	}
+
+	// page is parsed at last, so blocks defined in page override the ones in layouts
+	if err := parseTemplateFile(tpl, page); err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
+}
+
+func parseTemplateFile(tpl *template.Template, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tpl.Parse(string(content)); err != nil {
+		return fmt.Errorf("parse template %s failed: %v", path, err)
+	}
+
+	return nil
+}
+
+// funcs return the helper functions, request scoped helpers are placeholders which are replaced when rendering
+func (engine *ViewEngine) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"asset": func(path string) string {
+			return strings.TrimRight(engine.opts.AssetPrefix, "/") + "/" + strings.TrimLeft(path, "/")
+		},
+		"route": func(name string, pairs ...interface{}) (string, error) {
+			if engine.router == nil {
+				return "", fmt.Errorf("view engine is not bound to a router")
+			}
+
+			return engine.router.RouteURL(name, pairs...)
+		},
+	}
+
+	for k, v := range engine.requestFuncs(func() Context { return nil }) {
+		funcs[k] = v
+	}
+
+	for k, v := range engine.opts.Funcs {
+		funcs[k] = v
+	}
+
+	return funcs
+}
+
+// requestFuncs return helper functions depend on current request, current returns the context of the request being rendered
+func (engine *ViewEngine) requestFuncs(current func() Context) template.FuncMap {
+	csrfToken := func() string {
+		ctx := current()
+		if ctx == nil {
+			return ""
+		}
+
+		if token, ok := ctx.Get(CSRFTokenKey).(string); ok {
+			return token
+		}
+
+		return ""
+	}
+
+	return template.FuncMap{
+		"csrf_token": csrfToken,
+		"csrf_field": func() template.HTML {
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, CSRFTokenKey, template.HTMLEscapeString(csrfToken())))
+		},
+	}
+}
+
+// ViewResponse is a response rendered by ViewEngine
+type ViewResponse struct {
+	response Responsor
+	ctx      Context
+	name     string
+	layout   string
+	data     interface{}
+	code     int
+}
+
+func (resp *ViewResponse) Code() int {
+	return resp.code
+}
+
+// NewViewResponse create a ViewResponse, the view engine is resolved from container when sending
+func NewViewResponse(ctx Context, code int, name string, data interface{}) *ViewResponse {
+	resp := &ViewResponse{
+		response: ctx.Response(),
+		ctx:      ctx,
+		name:     name,
+		data:     data,
+		code:     code,
+	}
+
+	if engine, err := ctx.Container().Get((*ViewEngine)(nil)); err == nil {
+		resp.layout = engine.(*ViewEngine).opts.DefaultLayout
+	}
+
+	return resp
+}
+
+// WithCode set response code and return itself
+func (resp *ViewResponse) WithCode(code int) *ViewResponse {
+	resp.code = code
+	return resp
+}
+
+// WithLayout set the layout and return itself, empty layout means rendering page only
+func (resp *ViewResponse) WithLayout(layout string) *ViewResponse {
+	resp.layout = layout
+	return resp
+}
+
+// Send render the view and create response
+func (resp *ViewResponse) Send() error {
+	var buf bytes.Buffer
+	if err := resp.render(&buf); err != nil {
+		resp.code = http.StatusInternalServerError
+		_ = NewErrorResponse(resp.response, http.StatusText(resp.code), resp.code).Send()
+		return fmt.Errorf("render view %s failed: %v", resp.name, err)
+	}
+
+	resp.response.SetCode(resp.code)
+	resp.response.Header("Content-Type", "text/html; charset=utf-8")
+	resp.response.SetContent(buf.Bytes())
+
+	resp.response.Flush()
+	return nil
+}
+
+func (resp *ViewResponse) render(w io.Writer) error {
+	engine, err := resp.ctx.Container().Get((*ViewEngine)(nil))
+	if err != nil {
+		return fmt.Errorf("no view engine registered: %v", err)
+	}
+
+	return engine.(*ViewEngine).Render(w, resp.ctx, resp.name, resp.layout, resp.data)
+}
@@ -0,0 +1,687 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// WebSocket message types defined in RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// WebSocket close codes defined in RFC 6455
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+// maxPreallocatedPayload is the max payload length allocated before it's received
+const maxPreallocatedPayload = 64 << 10
+
+// websocketGUID is the magic string used for computing Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWebSocketClosed is the error when writing to a closed websocket connection
+var ErrWebSocketClosed = errors.New("websocket connection closed")
+
+// CloseError is the error returned by WebSocketConn.ReadMessage when a close frame received
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Text)
+}
+
+// WebSocketOptions is the options for websocket routes
+type WebSocketOptions struct {
+	// Subprotocols is the supported subprotocols in order of preference
+	Subprotocols []string
+	// EnableCompression enable permessage-deflate extension if client supports it
+	EnableCompression bool
+	// ReadLimit is the max bytes of a message, 0 means no limit
+	ReadLimit int64
+	// CheckOrigin return whether the request origin is allowed, same origin is required if it's nil
+	CheckOrigin func(r *http.Request) bool
+}
+
+// DefaultWebSocketOptions create a default websocket options
+func DefaultWebSocketOptions() WebSocketOptions {
+	return WebSocketOptions{
+		ReadLimit: 32 << 20, // 32M
+	}
+}
+
+// WebSocket add a websocket route, the handler will be called after the connection upgraded
+// *WebSocketConn can be injected into handler alongside Context
+func (router *Router) WebSocket(pattern string, handler interface{}) Route {
+	return router.WebSocketWithOptions(pattern, handler, DefaultWebSocketOptions())
+}
+
+// WebSocketWithOptions add a websocket route with options
+func (router *Router) WebSocketWithOptions(pattern string, handler interface{}, opts WebSocketOptions) Route {
+	return router.Get(pattern, func(ctx Context, route Route) Response {
+		conn, err := upgradeWebSocket(ctx, opts)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var httpErr Error
+			if errors.As(err, &httpErr) {
+				code = httpErr.StatusCode()
+			}
+
+			return ctx.Error(err.Error(), code)
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				_ = conn.Close(CloseInternalServerErr, "")
+				router.reportPanic(ctx, err, debug.Stack())
+			}
+		}()
+
+		provider, _ := router.cc.Provider(
+			func() Context { return ctx },
+			func() Request { return ctx.Request() },
+			func() Responsor { return ctx.Response() },
+			func() Route { return route },
+			func() *WebSocketConn { return conn },
+		)
+
+		results, err := router.cc.CallWithProvider(handler, provider)
+		if err == nil {
+			for _, res := range results {
+				if e, ok := res.(error); ok && e != nil {
+					err = e
+				}
+			}
+		}
+
+		if err != nil {
+			if router.logger != nil {
+				router.logger.Errorf("websocket handler failed: %v", err)
+			}
+
+			_ = conn.Close(CloseInternalServerErr, "")
+		} else {
+			_ = conn.Close(CloseNormalClosure, "")
+		}
+
+		return ctx.Nil()
+	})
+}
+
+// upgradeWebSocket validate the handshake request and upgrade the connection to websocket
+// handshake failures are returned as Error with the status code should be responded
+func upgradeWebSocket(ctx Context, opts WebSocketOptions) (*WebSocketConn, error) {
+	r := ctx.Request().Raw()
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, WrapPlainError(errors.New("websocket: not a websocket handshake"), http.StatusBadRequest)
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		ctx.Response().Header("Sec-WebSocket-Version", "13")
+		return nil, WrapPlainError(errors.New("websocket: unsupported version"), http.StatusUpgradeRequired)
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, WrapPlainError(errors.New("websocket: invalid Sec-WebSocket-Key"), http.StatusBadRequest)
+	}
+
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+
+	if !checkOrigin(r) {
+		return nil, WrapPlainError(errors.New("websocket: origin not allowed"), http.StatusForbidden)
+	}
+
+	var subprotocol string
+	for _, p := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		if stringIn(p, opts.Subprotocols) {
+			subprotocol = p
+			break
+		}
+	}
+
+	compress := false
+	if opts.EnableCompression {
+		for _, ext := range headerTokens(r.Header, "Sec-WebSocket-Extensions") {
+			if strings.TrimSpace(strings.Split(ext, ";")[0]) == "permessage-deflate" {
+				compress = true
+				break
+			}
+		}
+	}
+
+	netConn, rw, err := ctx.Response().Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "websocket: hijack failed")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	for k, values := range ctx.Response().Headers() {
+		for _, v := range values {
+			buf.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	for _, cookie := range ctx.Response().Cookies() {
+		if v := cookie.String(); v != "" {
+			buf.WriteString("Set-Cookie: " + v + "\r\n")
+		}
+	}
+	buf.WriteString("\r\n")
+
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		_ = netConn.Close()
+		return nil, errors.Wrap(err, "websocket: write handshake response failed")
+	}
+
+	ctx.Response().SetCode(http.StatusSwitchingProtocols)
+
+	return &WebSocketConn{
+		conn:        netConn,
+		reader:      rw.Reader,
+		request:     ctx.Request(),
+		subprotocol: subprotocol,
+		compress:    compress,
+		readLimit:   opts.ReadLimit,
+	}, nil
+}
+
+// websocketAccept compute the Sec-WebSocket-Accept value for key
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin return whether the Origin header is absent or has same host with request
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerTokens return all comma separated tokens in header values
+func headerTokens(header http.Header, key string) []string {
+	tokens := make([]string, 0)
+	for _, v := range header[http.CanonicalHeaderKey(key)] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+
+	return tokens
+}
+
+// headerContainsToken return whether the header contains token, case insensitive
+func headerContainsToken(header http.Header, key string, token string) bool {
+	for _, t := range headerTokens(header, key) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebSocketConn is a websocket connection
+type WebSocketConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	request     Request
+	subprotocol string
+	compress    bool
+	readLimit   int64
+
+	writeLock   sync.Mutex
+	closeOnce   sync.Once
+	closed      bool
+	pongHandler func(data []byte)
+}
+
+// Request return the handshake request
+func (c *WebSocketConn) Request() Request {
+	return c.request
+}
+
+// Subprotocol return the negotiated subprotocol
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr return the remote network address
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit set the max bytes of a message, 0 means no limit
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline set the deadline for reading messages
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline set the deadline for writing messages
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler set a handler for pong messages
+func (c *WebSocketConn) SetPongHandler(fn func(data []byte)) {
+	c.pongHandler = fn
+}
+
+// ReadMessage read a complete message, fragmented messages are reassembled
+// ping messages are replied automatically, a *CloseError will be returned when the peer closed the connection
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	var buf bytes.Buffer
+	compressed := false
+
+	for {
+		fin, rsv1, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, payload, false); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			closeErr := &CloseError{Code: CloseNoStatusReceived}
+			if len(payload) == 1 {
+				return 0, nil, c.fail(CloseProtocolError, "invalid close frame")
+			}
+
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Text = string(payload[2:])
+
+				if !validCloseCode(closeErr.Code) {
+					return 0, nil, c.fail(CloseProtocolError, "invalid close code")
+				}
+
+				if !utf8.ValidString(closeErr.Text) {
+					return 0, nil, c.fail(CloseInvalidPayloadData, "invalid utf8 close reason")
+				}
+			}
+
+			code := closeErr.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			_ = c.Close(code, "")
+
+			return 0, nil, closeErr
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expect continuation frame")
+			}
+
+			messageType = int(opcode)
+			compressed = rsv1
+		}
+
+		if rsv1 && opcode == continuationFrame {
+			return 0, nil, c.fail(CloseProtocolError, "unexpected rsv1 bit")
+		}
+
+		if c.readLimit > 0 && int64(buf.Len()+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+
+		buf.Write(payload)
+
+		if fin {
+			break
+		}
+	}
+
+	data = buf.Bytes()
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidPayloadData, "invalid utf8 text")
+	}
+
+	return messageType, data, nil
+}
+
+// ReadJSON read a message and decode it as json
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage write a message to peer, data messages are compressed if permessage-deflate negotiated
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		if len(data) > 125 {
+			return errors.New("websocket: control frame payload too large")
+		}
+
+		return c.writeFrame(byte(messageType), data, false)
+	}
+
+	if c.compress {
+		compressed, err := deflate(data)
+		if err != nil {
+			return err
+		}
+
+		return c.writeFrame(byte(messageType), compressed, true)
+	}
+
+	return c.writeFrame(byte(messageType), data, false)
+}
+
+// WriteText write a text message to peer
+func (c *WebSocketConn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+// WriteJSON encode v as json and write it as a text message
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Ping send a ping message to peer
+func (c *WebSocketConn) Ping(data []byte) error {
+	return c.WriteMessage(PingMessage, data)
+}
+
+// Close send a close frame and close the underlying connection
+func (c *WebSocketConn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+
+		_ = c.writeFrame(CloseMessage, payload, false)
+
+		c.writeLock.Lock()
+		c.closed = true
+		c.writeLock.Unlock()
+
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+// validCloseCode return whether the close code can be sent in a close frame(RFC 6455 section 7.4)
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
+
+// fail close the connection with code and return a error
+func (c *WebSocketConn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Text: reason}
+}
+
+// readFrame read a single frame from peer
+func (c *WebSocketConn) readFrame() (fin bool, rsv1 bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	rsv1 = header[0]&0x40 != 0
+	opcode = header[0] & 0x0f
+
+	if header[0]&0x30 != 0 || (rsv1 && !c.compress) {
+		err = c.fail(CloseProtocolError, "unexpected rsv bits")
+		return
+	}
+
+	if header[1]&0x80 == 0 {
+		err = c.fail(CloseProtocolError, "client frames must be masked")
+		return
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+
+		// the most significant bit must be 0(RFC 6455 section 5.2)
+		if length>>63 != 0 {
+			err = c.fail(CloseProtocolError, "invalid payload length")
+			return
+		}
+	}
+
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > 125 {
+			err = c.fail(CloseProtocolError, "invalid control frame")
+			return
+		}
+
+		if rsv1 {
+			err = c.fail(CloseProtocolError, "control frames must not be compressed")
+			return
+		}
+	default:
+		// reserved opcodes 0x3-0x7 and 0xB-0xF
+		err = c.fail(CloseProtocolError, "unknown opcode")
+		return
+	}
+
+	if c.readLimit > 0 && length > uint64(c.readLimit) {
+		err = c.fail(CloseMessageTooBig, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+
+	// the payload is read in chunks, so memory is only allocated for the data actually received
+	var buf bytes.Buffer
+	if length <= maxPreallocatedPayload {
+		buf.Grow(int(length))
+	}
+
+	if _, err = io.CopyN(&buf, c.reader, int64(length)); err != nil {
+		return
+	}
+	payload = buf.Bytes()
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// writeFrame write a single unmasked frame to peer
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte, rsv1 bool) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.closed {
+		return ErrWebSocketClosed
+	}
+
+	header := make([]byte, 0, 10)
+	b0 := 0x80 | opcode
+	if rsv1 {
+		b0 |= 0x40
+	}
+	header = append(header, b0)
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deflateTail is the tail of a flushed deflate block, which is removed from compressed messages
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// deflate compress a message without context takeover
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// inflate decompress a message without context takeover
+func (c *WebSocketConn) inflate(data []byte) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff}),
+	))
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var src io.Reader = reader
+	if c.readLimit > 0 {
+		src = io.LimitReader(reader, c.readLimit+1)
+	}
+
+	res, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, c.fail(CloseInvalidPayloadData, "invalid compressed data")
+	}
+
+	if c.readLimit > 0 && int64(len(res)) > c.readLimit {
+		return nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	return res, nil
+}
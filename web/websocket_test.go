@@ -0,0 +1,417 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/container"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func newWebSocketTestServer(opts WebSocketOptions) *httptest.Server {
+	router := NewRouter(container.New(), DefaultConfig())
+	router.WebSocketWithOptions("/ws", func(conn *WebSocketConn) error {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return nil
+			}
+
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return err
+			}
+		}
+	}, opts)
+
+	return httptest.NewServer(router)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	opts := DefaultWebSocketOptions()
+	opts.Subprotocols = []string{"chat", "superchat"}
+	opts.EnableCompression = true
+
+	srv := newWebSocketTestServer(opts)
+	defer srv.Close()
+
+	testCases := []struct {
+		name   string
+		header map[string]string
+		code   int
+		expect map[string]string
+	}{
+		{
+			name: "upgrade",
+			code: http.StatusSwitchingProtocols,
+			expect: map[string]string{
+				"Upgrade":              "websocket",
+				"Sec-WebSocket-Accept": "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+			},
+		},
+		{
+			name:   "subprotocol",
+			header: map[string]string{"Sec-WebSocket-Protocol": "superchat, chat"},
+			code:   http.StatusSwitchingProtocols,
+			expect: map[string]string{"Sec-WebSocket-Protocol": "superchat"},
+		},
+		{
+			name:   "compression",
+			header: map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits"},
+			code:   http.StatusSwitchingProtocols,
+			expect: map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; server_no_context_takeover; client_no_context_takeover"},
+		},
+		{
+			name:   "not a websocket handshake",
+			header: map[string]string{"Upgrade": "h2c"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "unsupported version",
+			header: map[string]string{"Sec-WebSocket-Version": "8"},
+			code:   http.StatusUpgradeRequired,
+			expect: map[string]string{"Sec-WebSocket-Version": "13"},
+		},
+		{
+			name:   "invalid key",
+			header: map[string]string{"Sec-WebSocket-Key": "invalid"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "cross origin",
+			header: map[string]string{"Origin": "http://evil.example.com"},
+			code:   http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s: expect code %d, got %d", tc.name, tc.code, resp.StatusCode)
+		}
+
+		for k, v := range tc.expect {
+			if resp.Header.Get(k) != v {
+				t.Errorf("%s: expect header %s=%q, got %q", tc.name, k, v, resp.Header.Get(k))
+			}
+		}
+	}
+}
+
+func TestWebSocketFraming(t *testing.T) {
+	opts := DefaultWebSocketOptions()
+	opts.ReadLimit = 1024
+
+	testCases := []struct {
+		name   string
+		frames [][]byte
+		// expect is the frames sent by server
+		expect []testFrame
+	}{
+		{
+			name:   "text echo",
+			frames: [][]byte{maskedFrame(true, TextMessage, []byte("hello"))},
+			expect: []testFrame{{opcode: TextMessage, payload: []byte("hello")}},
+		},
+		{
+			name: "fragmented message",
+			frames: [][]byte{
+				maskedFrame(false, BinaryMessage, []byte("hel")),
+				maskedFrame(true, PingMessage, []byte("p")),
+				maskedFrame(true, continuationFrame, []byte("lo")),
+			},
+			expect: []testFrame{
+				{opcode: PongMessage, payload: []byte("p")},
+				{opcode: BinaryMessage, payload: []byte("hello")},
+			},
+		},
+		{
+			name:   "close",
+			frames: [][]byte{maskedFrame(true, CloseMessage, closePayload(CloseGoingAway, "bye"))},
+			expect: []testFrame{{opcode: CloseMessage, payload: closePayload(CloseGoingAway, "")}},
+		},
+		{
+			name:   "unmasked frame",
+			frames: [][]byte{{0x81, 0x02, 'h', 'i'}},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "reserved data opcode",
+			frames: [][]byte{maskedFrame(true, 0x3, nil)},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "reserved control opcode",
+			frames: [][]byte{maskedFrame(true, 0xB, nil)},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "fragmented control frame",
+			frames: [][]byte{maskedFrame(false, PingMessage, nil)},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "payload length with most significant bit",
+			frames: [][]byte{{0x82, 0xff, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "message too big",
+			frames: [][]byte{maskedFrame(true, BinaryMessage, make([]byte, 2048))},
+			expect: []testFrame{closeFrame(CloseMessageTooBig)},
+		},
+		{
+			name:   "invalid utf8 text",
+			frames: [][]byte{maskedFrame(true, TextMessage, []byte{0xff, 0xfe})},
+			expect: []testFrame{closeFrame(CloseInvalidPayloadData)},
+		},
+		{
+			name:   "invalid close code",
+			frames: [][]byte{maskedFrame(true, CloseMessage, closePayload(CloseNoStatusReceived, ""))},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "close code out of range",
+			frames: [][]byte{maskedFrame(true, CloseMessage, closePayload(5000, ""))},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+		{
+			name:   "unexpected continuation frame",
+			frames: [][]byte{maskedFrame(true, continuationFrame, []byte("x"))},
+			expect: []testFrame{closeFrame(CloseProtocolError)},
+		},
+	}
+
+	srv := newWebSocketTestServer(opts)
+	defer srv.Close()
+
+	for _, tc := range testCases {
+		conn, r := dialWebSocket(t, srv, "")
+
+		for _, frame := range tc.frames {
+			if _, err := conn.Write(frame); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+
+		for i, expect := range tc.expect {
+			frame, err := readTestFrame(r)
+			if err != nil {
+				t.Errorf("%s: frame %d: %v", tc.name, i, err)
+				break
+			}
+
+			if frame.opcode != expect.opcode || !bytes.Equal(frame.payload[:minInt(len(frame.payload), len(expect.payload))], expect.payload) {
+				t.Errorf("%s: frame %d: expect %d %q, got %d %q", tc.name, i, expect.opcode, expect.payload, frame.opcode, frame.payload)
+			}
+		}
+
+		_ = conn.Close()
+	}
+}
+
+func TestWebSocketUnlimitedPayloadLength(t *testing.T) {
+	opts := DefaultWebSocketOptions()
+	opts.ReadLimit = 0
+
+	srv := newWebSocketTestServer(opts)
+	defer srv.Close()
+
+	conn, r := dialWebSocket(t, srv, "")
+	defer conn.Close()
+
+	// a frame claims 1TB payload, the server must not allocate it before receiving
+	header := []byte{0x82, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[2:10], 1<<40)
+	if _, err := conn.Write(append(header, []byte("data")...)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.(*net.TCPConn).CloseWrite()
+
+	// the truncated message is never delivered, the connection is closed when the peer is gone
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if frame, err := readTestFrame(r); err == nil && frame.opcode != CloseMessage {
+		t.Errorf("expect connection closed, got opcode %d", frame.opcode)
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	opts := DefaultWebSocketOptions()
+	opts.EnableCompression = true
+
+	srv := newWebSocketTestServer(opts)
+	defer srv.Close()
+
+	conn, r := dialWebSocket(t, srv, "permessage-deflate")
+	defer conn.Close()
+
+	message := strings.Repeat("compressed message ", 20)
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = w.Write([]byte(message))
+	_ = w.Flush()
+	compressed := bytes.TrimSuffix(buf.Bytes(), deflateTail)
+
+	frame := maskedFrame(true, TextMessage, compressed)
+	frame[0] |= 0x40
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	echo, err := readTestFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !echo.rsv1 || echo.opcode != TextMessage {
+		t.Fatalf("expect compressed text message, got opcode %d rsv1 %v", echo.opcode, echo.rsv1)
+	}
+
+	data, err := ioutil.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(echo.payload), bytes.NewReader(deflateTail))))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+
+	if string(data) != message {
+		t.Errorf("expect %q, got %q", message, data)
+	}
+}
+
+type testFrame struct {
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+func closeFrame(code int) testFrame {
+	return testFrame{opcode: CloseMessage, payload: closePayload(code, "")}
+}
+
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, extensions string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	if extensions != "" {
+		req.Header.Set("Sec-WebSocket-Extensions", extensions)
+	}
+
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expect code %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	return conn, r
+}
+
+// maskedFrame create a client frame, the payload is masked by a fixed key
+func maskedFrame(fin bool, opcode byte, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+
+	frame := []byte{b0}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 0x80|126, byte(length>>8), byte(length))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(length))
+		frame = append(append(frame, 0x80|127), ext...)
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+func readTestFrame(r *bufio.Reader) (testFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return testFrame{}, err
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return testFrame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return testFrame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return testFrame{}, err
+	}
+
+	return testFrame{rsv1: header[0]&0x40 != 0, opcode: header[0] & 0x0f, payload: payload}, nil
+}